@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ketilovre/launch/lib"
+	"github.com/spf13/cobra"
+)
+
+var abortCmd = &cobra.Command{
+	Use:     "abort",
+	Short:   "Route all traffic back to the stable version",
+	Example: "launch abort -e prod",
+	Long: `
+Cancels a release started with 'launch --canary', removing the weighted routing so the
+environment alias only serves the version it pointed at before.`,
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		startSession()
+
+		version, err := launch.AbortCanary(conf)
+		if err != nil {
+			fail("Unable to abort canary", err)
+		}
+
+		finish(map[string]string{"environment": conf.Environment, "dropped_version": version}, func() {
+			fmt.Printf("Dropped version %v from '%v'\n", version, conf.Environment)
+		})
+	}),
+}
+
+func init() {
+	RootCmd.AddCommand(abortCmd)
+}
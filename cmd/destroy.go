@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/ketilovre/launch/lib"
+	"github.com/spf13/cobra"
+)
+
+var confirmed bool
+
+var destroyCmd = &cobra.Command{
+	Use:     "destroy",
+	Short:   "Remove an environment",
+	Example: "launch destroy -e staging\nlaunch destroy -e staging --yes",
+	Long: `
+Removes the alias, stage, usage plan, rules, event subscriptions and static files of an
+environment. When it was the last environment, the functions, the API and the roles
+launch created are removed too. Log groups are kept.`,
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		if !confirmed && jsonOutput() {
+			fail("", usageError{fmt.Errorf("removing '%v' needs --yes with JSON output", conf.Environment)})
+		}
+		if !confirmed {
+			fmt.Printf("Type the name of the environment to remove '%v': ", conf.Environment)
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if strings.TrimSpace(answer) != conf.Environment {
+				fmt.Println("Nothing was removed")
+				os.Exit(1)
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		deployer, err := launch.NewDeployer(conf)
+		if err != nil {
+			fail("", err)
+		}
+
+		if err = deployer.Destroy(ctx); err != nil {
+			fail(fmt.Sprintf("Unable to remove '%v'", conf.Environment), err)
+		}
+
+		finish(map[string]string{"environment": conf.Environment}, func() {
+			fmt.Printf("Removed '%v'\n", conf.Environment)
+		})
+	}),
+}
+
+func init() {
+	destroyCmd.Flags().BoolVarP(&confirmed, "yes", "y", false, "don't ask for confirmation")
+	RootCmd.AddCommand(destroyCmd)
+}
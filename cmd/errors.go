@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ketilovre/launch/lib"
+)
+
+// configError holds the problems found in launch.yml.
+type configError []error
+
+func (e configError) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// usageError is returned when a command is called with the wrong arguments.
+type usageError struct {
+	error
+}
+
+// explain adds a hint on what to do about an error, based on its type.
+func explain(err error) string {
+	if h := hint(err); h != "" {
+		return fmt.Sprintf("%v\n%v", err, h)
+	}
+	return err.Error()
+}
+
+func hint(err error) string {
+	var (
+		permission *launch.PermissionError
+		throttled  *launch.ThrottledError
+		notFound   *launch.NotFoundError
+		validation *launch.ValidationError
+		account    *launch.AccountError
+	)
+
+	switch {
+	case errors.As(err, &account):
+		return fmt.Sprintf("Deploy with credentials of account %v, or change the 'account' of '%v' in launch.yml.", account.Expected, account.Environment)
+	case errors.As(err, &permission):
+		if permission.Action == "" {
+			return "The credentials used to deploy aren't allowed to do this. Check their IAM policies."
+		}
+		return fmt.Sprintf("The credentials used to deploy need permission for '%v'. Add it to their IAM policies, or deploy with another --profile or --assume-role.", permission.Action)
+	case errors.As(err, &throttled):
+		return "AWS is throttling requests to the account. Wait a minute and run the command again."
+	case errors.As(err, &notFound):
+		if conf.Account == "" {
+			return fmt.Sprintf("Check that it exists in region '%v', or remove it from launch.yml.", conf.Region)
+		}
+		return fmt.Sprintf("Check that it exists in account '%v' and region '%v', or remove it from launch.yml.", conf.Account, conf.Region)
+	case errors.As(err, &validation):
+		return "Check the matching settings in launch.yml."
+	}
+
+	return ""
+}
+
+// errorCode names the kind of an error in JSON output. Pipelines can rely on the codes not
+// changing.
+func errorCode(err error) string {
+	var (
+		config     configError
+		usage      usageError
+		account    *launch.AccountError
+		canary     *launch.CanaryAbortedError
+		hook       *launch.HookError
+		smoke      *launch.SmokeTestError
+		function   *launch.FunctionError
+		permission *launch.PermissionError
+		throttled  *launch.ThrottledError
+		notFound   *launch.NotFoundError
+		validation *launch.ValidationError
+	)
+
+	switch {
+	case errors.As(err, &config):
+		return "invalid_config"
+	case errors.As(err, &usage):
+		return "invalid_usage"
+	case errors.As(err, &account):
+		return "account_mismatch"
+	case errors.As(err, &canary):
+		return "canary_aborted"
+	case errors.As(err, &hook):
+		return "hook_failed"
+	case errors.As(err, &smoke):
+		return "smoke_test_failed"
+	case errors.As(err, &function):
+		return "function_error"
+	case errors.As(err, &permission):
+		return "permission_denied"
+	case errors.As(err, &throttled):
+		return "throttled"
+	case errors.As(err, &notFound):
+		return "not_found"
+	case errors.As(err, &validation):
+		return "invalid_request"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	}
+
+	return "failed"
+}
+
+// deniedAction returns the IAM action a PermissionError was denied, if any.
+func deniedAction(err error) string {
+	var permission *launch.PermissionError
+	if errors.As(err, &permission) {
+		return permission.Action
+	}
+	return ""
+}
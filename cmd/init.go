@@ -1,10 +1,7 @@
 package cmd
 
 import (
-	"fmt"
-
 	"os"
-	"strings"
 
 	"github.com/ketilovre/launch/lib"
 	"github.com/spf13/cobra"
@@ -23,21 +20,25 @@ func init() {
 }
 
 func doInit(cmd *cobra.Command, args []string) {
+	created := []string{}
+
 	_, err := os.Open("launch.yml")
-	if err != nil && strings.Contains(err.Error(), "no such file") {
+	if os.IsNotExist(err) {
 		if err := launch.BootstrapConfig(); err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			fail("", err)
 		}
-		fmt.Println("Bootstrapped config file")
+		created = append(created, "launch.yml")
+		printNotice(launch.Info{Message: "Bootstrapped config file"})
 	}
 
 	_, err = os.Open("server")
-	if err != nil && strings.Contains(err.Error(), "no such file") {
+	if os.IsNotExist(err) {
 		if err = launch.CreateServerFile(); err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			fail("", err)
 		}
-		fmt.Println("Bootstrapped server file")
+		created = append(created, "server")
+		printNotice(launch.Info{Message: "Bootstrapped server file"})
 	}
+
+	finish(map[string][]string{"created": created}, func() {})
 }
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ketilovre/launch/lib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	invokeHeaders []string
+	invokeData    string
+	invokeVersion string
+)
+
+var invokeCmd = &cobra.Command{
+	Use:   "invoke [method] <path>",
+	Short: "Send a request straight to the function",
+	Example: `launch invoke -e dev /users?id=1
+launch invoke -e dev POST /users -H 'Content-Type: application/json' -d @body.json
+launch invoke -e prod --version 42 GET /health`,
+	Long: `
+Builds the event API Gateway would send for a request, and invokes the alias of the
+environment with it, bypassing API Gateway. Prints the response of the app and the tail
+of the log of the invocation.
+
+--version invokes a published version of the app function instead of the alias, like a
+new version that hasn't been promoted yet. It can't be used with paths of routes. -d takes the body, or @file to read it from a file.`,
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		inv := launch.Invocation{Method: "GET", Version: invokeVersion, Headers: map[string]string{}}
+		switch len(args) {
+		case 1:
+			inv.Path = args[0]
+		case 2:
+			inv.Method, inv.Path = args[0], args[1]
+		default:
+			fail("", usageError{errors.New("expected a path, optionally preceded by a method, see 'launch help invoke'")})
+		}
+
+		if !strings.HasPrefix(inv.Path, "/") {
+			fail("", usageError{fmt.Errorf("path '%v' must start with /", inv.Path)})
+		}
+
+		for _, header := range invokeHeaders {
+			parts := strings.SplitN(header, ":", 2)
+			if len(parts) != 2 {
+				fail("", usageError{fmt.Errorf("header '%v' must look like 'Name: value'", header)})
+			}
+			inv.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+
+		inv.Body = invokeData
+		if strings.HasPrefix(invokeData, "@") {
+			body, err := os.ReadFile(invokeData[1:])
+			if err != nil {
+				fail("Unable to read body", err)
+			}
+			inv.Body = string(body)
+		}
+
+		startSession()
+
+		result, err := launch.Invoke(inv, conf)
+		if result != nil && result.Log != "" && err != nil {
+			printNotice(launch.Info{Message: result.Log})
+		}
+		if err != nil {
+			fail("", err)
+		}
+
+		finish(map[string]interface{}{
+			"function":    result.Function,
+			"qualifier":   result.Qualifier,
+			"version":     result.Version,
+			"status":      result.StatusCode,
+			"headers":     result.Headers,
+			"body":        result.Body,
+			"log":         result.Log,
+			"duration_ms": result.Duration.Milliseconds(),
+		}, func() {
+			printInvokeResult(result)
+		})
+	}),
+}
+
+func init() {
+	invokeCmd.Flags().StringArrayVarP(&invokeHeaders, "header", "H", nil, "request header, like 'X-Foo: bar'")
+	invokeCmd.Flags().StringVarP(&invokeData, "data", "d", "", "request body, or @file to read it from a file")
+	invokeCmd.Flags().StringVar(&invokeVersion, "version", "", "published version to invoke instead of the alias")
+	RootCmd.AddCommand(invokeCmd)
+}
+
+func printInvokeResult(result *launch.InvokeResult) {
+	fmt.Printf("HTTP %v from '%v:%v' (version %v) in %v\n", result.StatusCode, result.Function, result.Qualifier, result.Version, result.Duration.Round(time.Millisecond))
+
+	var names []string
+	for name := range result.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%v: %v\n", name, result.Headers[name])
+	}
+
+	body := result.Body
+	var indented bytes.Buffer
+	if json.Indent(&indented, []byte(body), "", "  ") == nil {
+		body = indented.String()
+	}
+	fmt.Printf("\n%v\n", body)
+
+	if result.Log != "" {
+		fmt.Printf("\n--- Log ---\n%v\n", strings.TrimRight(result.Log, "\n"))
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ketilovre/launch/lib"
+	"github.com/spf13/cobra"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage API keys",
+	Long: `
+Create, list and revoke the API keys clients use to call the API. Keys belong to the
+usage plan of a single environment, and are throttled according to its settings.
+
+Key values are only printed when a key is created. They are never written to launch.yml.`,
+}
+
+var keysCreateCmd = &cobra.Command{
+	Use:     "create <client>",
+	Short:   "Create an API key for a client",
+	Example: "launch keys create acme -e prod",
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		requireClient(cmd, args)
+		startSession()
+
+		key, err := launch.CreateAPIKey(args[0], conf)
+		if err != nil {
+			fail("Unable to create key", err)
+		}
+
+		finish(map[string]string{"client": args[0], "environment": conf.Environment, "id": *key.Id, "key": *key.Value}, func() {
+			fmt.Printf("Created key for '%v' in '%v'. It will not be shown again.\n\n", args[0], conf.Environment)
+			fmt.Printf("    %v\n", *key.Value)
+		})
+	}),
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the API keys of an environment",
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		startSession()
+
+		keys, err := launch.ListAPIKeys(conf)
+		if err != nil {
+			fail("Unable to list keys", err)
+		}
+
+		type keyResult struct {
+			Client  string    `json:"client"`
+			ID      string    `json:"id"`
+			Enabled bool      `json:"enabled"`
+			Created time.Time `json:"created"`
+		}
+
+		results := []keyResult{}
+		for _, key := range keys {
+			results = append(results, keyResult{launch.APIKeyClient(key, conf), *key.Id, *key.Enabled, *key.CreatedDate})
+		}
+
+		finish(map[string]interface{}{"environment": conf.Environment, "keys": results}, func() {
+			if len(results) == 0 {
+				fmt.Printf("No keys in '%v'\n", conf.Environment)
+				return
+			}
+
+			for _, key := range results {
+				status := "enabled"
+				if !key.Enabled {
+					status = "disabled"
+				}
+				fmt.Printf("%-24v %-12v %-10v %v\n", key.Client, key.ID, status, key.Created.Format("2006-01-02"))
+			}
+		})
+	}),
+}
+
+var keysRevokeCmd = &cobra.Command{
+	Use:     "revoke <client>",
+	Short:   "Delete the API key of a client",
+	Example: "launch keys revoke acme -e prod",
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		requireClient(cmd, args)
+		startSession()
+
+		if err := launch.RevokeAPIKey(args[0], conf); err != nil {
+			fail("Unable to revoke key", err)
+		}
+
+		finish(map[string]string{"client": args[0], "environment": conf.Environment}, func() {
+			fmt.Printf("Revoked key for '%v' in '%v'\n", args[0], conf.Environment)
+		})
+	}),
+}
+
+func init() {
+	RootCmd.AddCommand(keysCmd)
+
+	keysCmd.AddCommand(keysCreateCmd)
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysRevokeCmd)
+}
+
+func requireClient(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fail("", usageError{fmt.Errorf("expected a single client name, see 'launch help keys %v'", cmd.Name())})
+	}
+}
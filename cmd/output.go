@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ketilovre/launch/lib"
+)
+
+// output is the format commands print in: text for people, or json for pipelines, with one
+// event per line and the result of the command last.
+var output string
+
+func jsonOutput() bool {
+	return output == "json"
+}
+
+// event is a line of JSON output.
+type event struct {
+	Event      string      `json:"event"`
+	Time       time.Time   `json:"time"`
+	Step       string      `json:"step,omitempty"`
+	Type       string      `json:"type,omitempty"`
+	Name       string      `json:"name,omitempty"`
+	Detail     string      `json:"detail,omitempty"`
+	DurationMS *int64      `json:"duration_ms,omitempty"`
+	Code       string      `json:"code,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Action     string      `json:"action,omitempty"`
+	Hint       string      `json:"hint,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+}
+
+func emit(e event) {
+	e.Time = time.Now().UTC()
+	json.NewEncoder(os.Stdout).Encode(e)
+}
+
+// printNotice prints what launch is doing. As text, steps aren't printed, the error of a
+// failed step is explained by the command instead.
+func printNotice(n launch.Notice) {
+	if jsonOutput() {
+		emit(noticeEvent(n))
+		return
+	}
+
+	switch n.(type) {
+	case launch.StepStarted, launch.StepFinished:
+		return
+	}
+	fmt.Println(n)
+}
+
+func noticeEvent(n launch.Notice) event {
+	switch n := n.(type) {
+	case launch.StepStarted:
+		return event{Event: "step_started", Step: n.Step}
+	case launch.StepFinished:
+		ms := n.Duration.Milliseconds()
+		e := event{Event: "step_finished", Step: n.Step, DurationMS: &ms}
+		if n.Err != nil {
+			e.Code = errorCode(n.Err)
+			e.Message = n.Err.Error()
+		}
+		return e
+	case launch.ResourceCreated:
+		return event{Event: "resource_created", Type: n.Type, Name: n.Name, Detail: n.Detail}
+	case launch.ResourceUpdated:
+		return event{Event: "resource_updated", Type: n.Type, Name: n.Name, Detail: n.Detail}
+	case launch.ResourceDeleted:
+		return event{Event: "resource_deleted", Type: n.Type, Name: n.Name}
+	case launch.Warning:
+		return event{Event: "warning", Message: n.Message}
+	}
+	return event{Event: "info", Message: n.String()}
+}
+
+// fail prints err along with a hint on what to do about it, and exits. Message tells what
+// failed, when the error doesn't.
+func fail(message string, err error) {
+	if jsonOutput() {
+		e := event{Event: "error", Code: errorCode(err), Message: err.Error(), Action: deniedAction(err), Hint: hint(err)}
+		if message != "" {
+			e.Message = fmt.Sprintf("%v: %v", message, e.Message)
+		}
+		emit(e)
+	} else if message != "" {
+		fmt.Printf("%v: %v\n", message, explain(err))
+	} else {
+		fmt.Println(explain(err))
+	}
+	os.Exit(1)
+}
+
+// finish prints the result of a command, as text or as the last event of JSON output.
+func finish(result interface{}, text func()) {
+	if jsonOutput() {
+		emit(event{Event: "result", Result: result})
+		return
+	}
+	text()
+}
+
+type deployResult struct {
+	Environment  string           `json:"environment"`
+	Account      string           `json:"account"`
+	FunctionARN  string           `json:"function_arn"`
+	Version      string           `json:"version"`
+	Alias        string           `json:"alias"`
+	APIID        string           `json:"api_id"`
+	Stage        string           `json:"stage"`
+	DeploymentID string           `json:"deployment_id"`
+	URL          string           `json:"url"`
+	DurationMS   int64            `json:"duration_ms"`
+	TimingsMS    map[string]int64 `json:"timings_ms"`
+	Warnings     []string         `json:"warnings"`
+}
+
+func newDeployResult(result *launch.Result) deployResult {
+	r := deployResult{
+		Environment:  result.Environment,
+		Account:      result.Account,
+		FunctionARN:  result.FunctionARN,
+		Version:      result.Version,
+		Alias:        result.Alias,
+		APIID:        result.APIID,
+		Stage:        result.Stage,
+		DeploymentID: result.DeploymentID,
+		URL:          result.URL,
+		TimingsMS:    map[string]int64{},
+		Warnings:     append([]string{}, result.Warnings...),
+	}
+	for _, timing := range result.Timings {
+		r.TimingsMS[timing.Step] = timing.Duration.Milliseconds()
+		r.DurationMS += timing.Duration.Milliseconds()
+	}
+	return r
+}
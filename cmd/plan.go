@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ketilovre/launch/lib"
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:     "plan",
+	Short:   "Show what a deployment would change",
+	Example: "launch plan -e prod",
+	Long: `
+Looks up the functions, roles, aliases, API, stage and rules of an environment, and lists
+which of them 'launch' would create, update or remove. Nothing is changed.`,
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		deployer, err := launch.NewDeployer(conf)
+		if err != nil {
+			fail("", err)
+		}
+
+		changes, err := deployer.Plan(context.Background())
+		if err != nil {
+			fail(fmt.Sprintf("Unable to plan '%v'", conf.Environment), err)
+		}
+
+		results := []map[string]string{}
+		for _, change := range changes {
+			results = append(results, map[string]string{"action": change.Action, "type": change.Type, "name": change.Name})
+		}
+
+		finish(map[string]interface{}{"environment": conf.Environment, "changes": results}, func() {
+			for _, change := range changes {
+				fmt.Printf("%-7v %v '%v'\n", change.Action, change.Type, change.Name)
+			}
+		})
+	}),
+}
+
+func init() {
+	RootCmd.AddCommand(planCmd)
+}
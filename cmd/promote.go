@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ketilovre/launch/lib"
+	"github.com/spf13/cobra"
+)
+
+var promoteCmd = &cobra.Command{
+	Use:     "promote",
+	Short:   "Route all traffic to the canary version",
+	Example: "launch promote -e prod",
+	Long: `
+Finishes a release started with 'launch --canary', pointing the environment alias at
+the new version and removing the weighted routing.`,
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		startSession()
+
+		version, err := launch.PromoteCanary(conf)
+		if err != nil {
+			fail("Unable to promote canary", err)
+		}
+
+		finish(map[string]string{"environment": conf.Environment, "version": version}, func() {
+			fmt.Printf("Promoted version %v in '%v'\n", version, conf.Environment)
+		})
+	}),
+}
+
+func init() {
+	RootCmd.AddCommand(promoteCmd)
+}
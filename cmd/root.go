@@ -1,38 +1,53 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/ketilovre/launch/lib"
-	"strings"
 )
 
 var (
 	conf *launch.Config
 
-	cfgFile string
-	env     string
-	port    int
-	region  string
+	cfgFile    string
+	env        string
+	port       int
+	region     string
+	profile    string
+	assumeRole string
+	externalID string
+	mfaSerial  string
+	canary     int
+
+	retries       int
+	retryDeadline time.Duration
+
+	identity *sts.GetCallerIdentityOutput
 )
 
 var RootCmd = &cobra.Command{
 	Use:     "launch",
 	Short:   "Deploy serverless applications on AWS",
-	Example: "launch\nlaunch -e prod",
+	Example: "launch\nlaunch -e prod\nlaunch -e prod --canary 10",
 	Run:     withValidConfig(launchCommand),
+
+	// Errors about unknown commands, flags and arguments are printed by fail, so they
+	// follow --output like every other error.
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(-1)
+		fail("", usageError{fmt.Errorf("%w, see 'launch help'", err)})
 	}
 }
 
@@ -43,11 +58,25 @@ func init() {
 	RootCmd.PersistentFlags().StringVarP(&env, "environment", "e", "dev", "target environment")
 	RootCmd.PersistentFlags().IntVarP(&port, "port", "p", 0, "application port")
 	RootCmd.PersistentFlags().StringVarP(&region, "region", "r", "", "AWS region")
+	RootCmd.PersistentFlags().StringVar(&profile, "profile", "", "named profile from the shared AWS config")
+	RootCmd.PersistentFlags().StringVar(&assumeRole, "assume-role", "", "ARN of a role to assume before deploying")
+	RootCmd.PersistentFlags().StringVar(&externalID, "external-id", "", "external ID to pass when assuming the role")
+	RootCmd.PersistentFlags().StringVar(&mfaSerial, "mfa-serial", "", "MFA device to prompt for a token when assuming the role")
+	RootCmd.PersistentFlags().IntVar(&retries, "retries", 10, "attempts of an AWS call that is throttled or waiting on IAM")
+	RootCmd.PersistentFlags().StringVar(&output, "output", "text", "print text, or newline-delimited JSON events with 'json'")
+	RootCmd.PersistentFlags().DurationVar(&retryDeadline, "retry-deadline", time.Minute*2, "time a run spends waiting on retries of AWS calls")
+
+	RootCmd.Flags().IntVar(&canary, "canary", 0, "percentage of traffic to route to the new version")
 }
 
 func initConfig() {
 	c := new(launch.Config)
 
+	if output != "text" && output != "json" {
+		fmt.Printf("Unknown output '%v', expected text or json\n", output)
+		os.Exit(1)
+	}
+
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
 	}
@@ -60,15 +89,16 @@ func initConfig() {
 	viper.SetDefault("environment", "dev")
 
 	if err := viper.ReadInConfig(); err != nil {
-		if strings.Contains(err.Error(), "cannot start any token") {
-			fmt.Printf("Couldn't read config file: %v\n", err)
-			fmt.Println("Check that your launch.yml doesn't contain any tabs. YAML only allows spaces.")
+		if _, malformed := err.(viper.ConfigParseError); malformed {
+			if tabs := launch.CheckTabs(viper.ConfigFileUsed()); tabs != nil {
+				err = tabs
+			}
+			printNotice(launch.Warning{Message: fmt.Sprintf("Couldn't read config file: %v", err)})
 		}
 	}
 
 	if err := viper.Unmarshal(c); err != nil {
-		fmt.Printf("Malformed config: %v", err)
-		os.Exit(1)
+		fail("Malformed config", configError{err})
 	}
 	conf = c
 
@@ -76,6 +106,8 @@ func initConfig() {
 		c.Environment = env
 	}
 
+	launch.ApplyEnvironment(c)
+
 	if port != 0 {
 		c.Port = port
 	}
@@ -83,11 +115,35 @@ func initConfig() {
 	if region != "" {
 		c.Region = region
 	}
+
+	if profile != "" {
+		c.Profile = profile
+	}
+
+	if assumeRole != "" {
+		c.AssumeRole = assumeRole
+	}
+
+	if externalID != "" {
+		c.ExternalID = externalID
+	}
+
+	if mfaSerial != "" {
+		c.MFASerial = mfaSerial
+	}
+
+	c.Canary.Weight = canary
+	c.Listener = launch.ListenerFunc(printNotice)
+	c.Retry.Attempts = retries
+	c.Retry.Deadline = retryDeadline
 }
 
 func withValidConfig(action func(*cobra.Command, []string)) func(*cobra.Command, []string) {
 	return func(cmd *cobra.Command, args []string) {
 		errs := launch.ValidateConfig(conf)
+		if errs != nil && jsonOutput() {
+			fail("", configError(errs))
+		}
 		if errs != nil {
 			for _, err := range errs {
 				fmt.Printf("Config error: %v\n", err)
@@ -98,37 +154,34 @@ func withValidConfig(action func(*cobra.Command, []string)) func(*cobra.Command,
 	}
 }
 
-func launchCommand(cmd *cobra.Command, args []string) {
-	conf.Session = session.New(&aws.Config{
-		Region: aws.String(conf.Region),
-	})
-
-	if err := launch.CheckServerFile(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+func startSession() {
+	sess, err := launch.NewSession(conf)
+	if err != nil {
+		fail("", err)
 	}
+	conf.Session = sess
 
-	fn, err := launch.CreateOrUpdateFunction(conf)
+	identity, err = launch.CheckAccount(conf)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fail("", err)
 	}
+}
 
-	if err = launch.GetOrCreateAPI(fn, conf); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
+func launchCommand(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	if err = launch.CreateOrUpdateFunctionWarmer(fn, conf); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	deployer, err := launch.NewDeployer(conf)
+	if err != nil {
+		fail("", err)
 	}
 
-	url, err := launch.GetInvokeUrl(conf)
+	result, err := deployer.Deploy(ctx)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fail("", err)
 	}
 
-	fmt.Printf("Service deployed to %v\n", url)
+	finish(newDeployResult(result), func() {
+		fmt.Printf("Service deployed to %v\n", result.URL)
+	})
 }
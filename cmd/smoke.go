@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/ketilovre/launch/lib"
+	"github.com/spf13/cobra"
+)
+
+var smokeURL string
+
+var smokeCmd = &cobra.Command{
+	Use:     "smoke",
+	Short:   "Run the smoke tests",
+	Example: "launch smoke -e prod\nlaunch smoke --url http://localhost:8080",
+	Long: `
+Sends the requests of 'smoke-tests' in launch.yml to the stage of an environment, or to
+the server at --url, and checks the responses. Nothing is rolled back when a test fails,
+that only happens as part of a deployment.`,
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		url := smokeURL
+		if url == "" {
+			startSession()
+
+			var err error
+			if url, err = launch.GetInvokeUrl(conf); err != nil {
+				fail("Unable to find the stage", err)
+			}
+		}
+
+		if err := launch.RunSmokeTests(ctx, url, conf); err != nil {
+			fail("", err)
+		}
+
+		finish(map[string]interface{}{"url": url, "passed": len(conf.SmokeTests)}, func() {
+			fmt.Printf("%v smoke test(s) passed against %v\n", len(conf.SmokeTests), url)
+		})
+	}),
+}
+
+func init() {
+	smokeCmd.Flags().StringVar(&smokeURL, "url", "", "base URL to test instead of the stage")
+	RootCmd.AddCommand(smokeCmd)
+}
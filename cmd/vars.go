@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ketilovre/launch/lib"
+	"github.com/spf13/cobra"
+)
+
+var varsCmd = &cobra.Command{
+	Use:   "vars",
+	Short: "Manage stage variables",
+	Long: `
+List and change the stage variables of an environment without deploying. The app reads
+them like environment variables.
+
+Changes made here last until the next deployment, which sets the variables of launch.yml
+again. 'environment' and 'staticBucket' are set by launch and can't be changed, and
+names are lowercase like the variables of launch.yml.`,
+}
+
+var varsListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the stage variables of an environment",
+	Example: "launch vars list -e prod",
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		startSession()
+
+		vars, err := launch.StageVariables(conf)
+		if err != nil {
+			fail("Unable to list variables", err)
+		}
+
+		finish(map[string]interface{}{"environment": conf.Environment, "variables": vars}, func() {
+			var names []string
+			for name := range vars {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				fmt.Printf("%-24v %v\n", name, vars[name])
+			}
+		})
+	}),
+}
+
+var varsSyncCmd = &cobra.Command{
+	Use:     "sync",
+	Short:   "Make the stage variables match launch.yml",
+	Example: "launch vars sync -e prod",
+	Long: `
+Sets the variables of launch.yml on the stage of an environment, and removes the
+variables that are no longer in it, without deploying.`,
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		startSession()
+
+		changes, err := launch.SyncStageVariables(conf)
+		if err != nil {
+			fail("Unable to sync variables", err)
+		}
+
+		synced := []map[string]string{}
+		for _, change := range changes {
+			synced = append(synced, map[string]string{"action": change.Action, "name": change.Name})
+		}
+
+		finish(map[string]interface{}{"environment": conf.Environment, "changes": synced}, func() {
+			if len(changes) == 0 {
+				fmt.Printf("The variables of '%v' already match launch.yml\n", conf.Environment)
+				return
+			}
+			fmt.Printf("Made %v change(s) to the variables of '%v'\n", len(changes), conf.Environment)
+		})
+	}),
+}
+
+var varsGetCmd = &cobra.Command{
+	Use:     "get <name>",
+	Short:   "Print a stage variable",
+	Example: "launch vars get apiUrl -e prod",
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		requireVarArgs(cmd, args, 1, "a variable name")
+		startSession()
+
+		vars, err := launch.StageVariables(conf)
+		if err != nil {
+			fail("Unable to read variables", err)
+		}
+
+		value, exists := vars[args[0]]
+		if !exists {
+			fail("", &launch.NotFoundError{Err: fmt.Errorf("stage '%v' has no variable '%v'", conf.Environment, args[0])})
+		}
+
+		finish(map[string]string{"environment": conf.Environment, "name": args[0], "value": value}, func() {
+			fmt.Println(value)
+		})
+	}),
+}
+
+var varsSetCmd = &cobra.Command{
+	Use:     "set <name> <value>",
+	Short:   "Set a stage variable",
+	Example: "launch vars set apiUrl https://api.example.com -e prod",
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		requireVarArgs(cmd, args, 2, "a variable name and a value")
+		startSession()
+
+		if err := launch.SetStageVariable(args[0], args[1], conf); err != nil {
+			fail("Unable to set variable", err)
+		}
+
+		finish(map[string]string{"environment": conf.Environment, "name": args[0], "value": args[1]}, func() {
+			fmt.Printf("Set '%v' in '%v'\n", args[0], conf.Environment)
+		})
+	}),
+}
+
+var varsUnsetCmd = &cobra.Command{
+	Use:     "unset <name>",
+	Short:   "Remove a stage variable",
+	Example: "launch vars unset apiUrl -e prod",
+	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
+		requireVarArgs(cmd, args, 1, "a variable name")
+		startSession()
+
+		if err := launch.UnsetStageVariable(args[0], conf); err != nil {
+			fail("Unable to remove variable", err)
+		}
+
+		finish(map[string]string{"environment": conf.Environment, "name": args[0]}, func() {
+			fmt.Printf("Removed '%v' from '%v'\n", args[0], conf.Environment)
+		})
+	}),
+}
+
+func init() {
+	RootCmd.AddCommand(varsCmd)
+
+	varsCmd.AddCommand(varsListCmd)
+	varsCmd.AddCommand(varsGetCmd)
+	varsCmd.AddCommand(varsSetCmd)
+	varsCmd.AddCommand(varsUnsetCmd)
+	varsCmd.AddCommand(varsSyncCmd)
+}
+
+func requireVarArgs(cmd *cobra.Command, args []string, n int, expected string) {
+	if len(args) != n {
+		fail("", usageError{fmt.Errorf("expected %v, see 'launch help vars %v'", expected, cmd.Name())})
+	}
+}
@@ -1,10 +1,6 @@
 package cmd
 
 import (
-	"fmt"
-
-	"os"
-
 	"github.com/ketilovre/launch/lib"
 	"github.com/spf13/cobra"
 )
@@ -16,10 +12,12 @@ var zipCmd = &cobra.Command{
 The zip command creates a package as it would have been deployed to Lambda, including
 the JS-shim, and writes it to disk.`,
 	Run: withValidConfig(func(cmd *cobra.Command, args []string) {
-		if err := launch.WriteZipToFile(cmd.Flag("out").Value.String(), conf); err != nil {
-			fmt.Printf("Unable to write zip file: %v\n", err)
-			os.Exit(1)
+		name := cmd.Flag("out").Value.String()
+		if err := launch.WriteZipToFile(name, conf); err != nil {
+			fail("Unable to write zip file", err)
 		}
+
+		finish(map[string]string{"file": name + ".zip"}, func() {})
 	}),
 }
 
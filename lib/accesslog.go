@@ -0,0 +1,129 @@
+package launch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// accessLogFormats are the presets available for the 'format' of access logs.
+var accessLogFormats = map[string]string{
+	"clf": `$context.identity.sourceIp $context.identity.caller $context.identity.user [$context.requestTime] "$context.httpMethod $context.resourcePath $context.protocol" $context.status $context.responseLength $context.requestId`,
+	"json": `{ "requestId":"$context.requestId", "ip": "$context.identity.sourceIp", "caller":"$context.identity.caller", ` +
+		`"user":"$context.identity.user", "requestTime":"$context.requestTime", "httpMethod":"$context.httpMethod", ` +
+		`"resourcePath":"$context.resourcePath", "status":"$context.status", "protocol":"$context.protocol", ` +
+		`"responseLength":"$context.responseLength" }`,
+	"csv": `$context.identity.sourceIp,$context.identity.caller,$context.identity.user,$context.requestTime,$context.httpMethod,$context.resourcePath,$context.protocol,$context.status,$context.responseLength,$context.requestId`,
+}
+
+func accessLogEnabled(conf *Config) bool {
+	return conf.AccessLog.Format != ""
+}
+
+// accessLogFormat returns the preset matching the configured format, or the format itself
+// when it is a custom template.
+func accessLogFormat(conf *Config) string {
+	if preset, defined := accessLogFormats[strings.ToLower(conf.AccessLog.Format)]; defined {
+		return preset
+	}
+	return conf.AccessLog.Format
+}
+
+// getOrCreateAccessLogGroup returns the ARN of the log group receiving the access logs of
+// the current environment, creating it and updating its retention as needed.
+func getOrCreateAccessLogGroup(conf *Config) (string, error) {
+	client := cloudwatchlogs.New(conf.Session)
+
+	group, err := getLogGroup(client, accessLogGroupName(conf))
+	if err != nil {
+		return "", err
+	}
+
+	if group == nil {
+		created(conf, "log group", accessLogGroupName(conf), "")
+		_, err = client.CreateLogGroup(&cloudwatchlogs.CreateLogGroupInput{
+			LogGroupName: aws.String(accessLogGroupName(conf)),
+		})
+		if err != nil {
+			return "", err
+		}
+
+		group, err = getLogGroup(client, accessLogGroupName(conf))
+		if err != nil {
+			return "", err
+		}
+
+		if group == nil {
+			return "", fmt.Errorf("log group '%v' was created, but can't be found", accessLogGroupName(conf))
+		}
+	}
+
+	retention := conf.AccessLog.Retention
+	if aws.Int64Value(group.RetentionInDays) != retention {
+		if retention == 0 {
+			_, err = client.DeleteRetentionPolicy(&cloudwatchlogs.DeleteRetentionPolicyInput{
+				LogGroupName: group.LogGroupName,
+			})
+		} else {
+			updated(conf, "log group", *group.LogGroupName, fmt.Sprintf("retention of %v days", retention))
+			_, err = client.PutRetentionPolicy(&cloudwatchlogs.PutRetentionPolicyInput{
+				LogGroupName:    group.LogGroupName,
+				RetentionInDays: aws.Int64(retention),
+			})
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimSuffix(*group.Arn, ":*"), nil
+}
+
+func getLogGroup(client *cloudwatchlogs.CloudWatchLogs, name string) (*cloudwatchlogs.LogGroup, error) {
+	groups, err := client.DescribeLogGroups(&cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups.LogGroups {
+		if *group.LogGroupName == name {
+			return group, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ensureCloudWatchRole gives API Gateway the account-wide role it needs to write logs,
+// unless the account already has one.
+func ensureCloudWatchRole(client *ag.APIGateway, account *ag.Account, conf *Config) error {
+	if aws.StringValue(account.CloudwatchRoleArn) != "" {
+		return nil
+	}
+
+	role, err := GetOrCreateCloudWatchRole(conf)
+	if err != nil {
+		return err
+	}
+
+	updated(conf, "API Gateway account", conf.Region, "CloudWatch role")
+	_, err = client.UpdateAccount(&ag.UpdateAccountInput{
+		PatchOperations: []*ag.PatchOperation{
+			{
+				Op:    aws.String(ag.OpReplace),
+				Path:  aws.String("/cloudwatchRoleArn"),
+				Value: role.Arn,
+			},
+		},
+	})
+	return err
+}
+
+func accessLogGroupName(conf *Config) string {
+	return fmt.Sprintf("/launch/%v/%v/access", conf.Name, conf.Environment)
+}
@@ -0,0 +1,50 @@
+package launch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccessLogFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", accessLogFormats["json"]},
+		{"CLF", accessLogFormats["clf"]},
+		{"$context.requestId $context.status", "$context.requestId $context.status"},
+	}
+
+	for _, tt := range tests {
+		if got := accessLogFormat(&Config{AccessLog: AccessLog{Format: tt.format}}); got != tt.want {
+			t.Errorf("format %q: got %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAccessLog(t *testing.T) {
+	tests := []struct {
+		log  AccessLog
+		want string
+	}{
+		{AccessLog{Format: "json"}, ""},
+		{AccessLog{Format: "clf", Retention: 30}, ""},
+		{AccessLog{Format: "clf", Retention: 31}, "'retention' must be one of"},
+		{AccessLog{Format: "$context.status"}, "$context.requestId"},
+	}
+
+	for _, tt := range tests {
+		conf := &Config{Name: "app", Region: "us-east-1", Port: 8000, AccessLog: tt.log}
+		errs := ValidateConfig(conf)
+
+		if tt.want == "" {
+			if len(errs) != 0 {
+				t.Errorf("expected %+v to be valid, got %v", tt.log, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.want) {
+			t.Errorf("expected %+v to be rejected for %q, got %v", tt.log, tt.want, errs)
+		}
+	}
+}
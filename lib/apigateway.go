@@ -2,6 +2,7 @@ package launch
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,46 +16,116 @@ var (
 	proxyPath = "{proxy+}"
 )
 
-func GetOrCreateAPI(fn *lambda.FunctionConfiguration, conf *Config) error {
+// GetOrCreateAPI sets up the API in front of the app function, and the route functions
+// keyed by route name.
+func GetOrCreateAPI(fn *lambda.FunctionConfiguration, routes map[string]*lambda.FunctionConfiguration, conf *Config) error {
 	client := ag.New(conf.Session)
 
 	api, err := getOrCreateRestAPI(client, conf)
 	if err != nil {
-		return fmt.Errorf("error creating API: %v", err)
+		return fmt.Errorf("error creating API: %w", err)
 	}
 
 	root, err := getResource(client, api, "")
 	if err != nil {
-		return fmt.Errorf("unable to retrieve root resource: %v", err)
+		return fmt.Errorf("unable to retrieve root resource: %w", err)
 	}
 
 	proxy, err := getOrCreateProxy(client, api, conf)
 	if err != nil {
-		return fmt.Errorf("error creating proxy resource: %v", err)
+		return fmt.Errorf("error creating proxy resource: %w", err)
 	}
 
-	if _, err = getOrCreateMethod(client, api, root); err != nil {
-		return fmt.Errorf("error creating ANY method for root resource: %v", err)
+	authorizer, err := getOrCreateAuthorizer(client, api, fn, conf)
+	if err != nil {
+		return fmt.Errorf("error creating authorizer: %w", err)
+	}
+
+	if _, err = getOrCreateMethod(client, api, root, methodAccess(*root.Path, authorizer, conf), conf); err != nil {
+		return fmt.Errorf("error creating ANY method for root resource: %w", err)
+	}
+
+	if _, err = getOrCreateMethod(client, api, proxy, methodAccess(*proxy.Path, authorizer, conf), conf); err != nil {
+		return fmt.Errorf("error creating ANY method for proxy resource: %w", err)
 	}
 
-	if _, err = getOrCreateMethod(client, api, proxy); err != nil {
-		return fmt.Errorf("error creating ANY method for proxy resource: %v", err)
+	fns := []*lambda.FunctionConfiguration{fn}
+	for _, routeFn := range routes {
+		fns = append(fns, routeFn)
 	}
 
-	role, err := GetOrCreateAPIRole(fn, conf)
+	role, err := GetOrCreateAPIRole(fns, conf)
 	if err != nil {
-		return fmt.Errorf("error creating AMI role for the API: %v", err)
+		return fmt.Errorf("error creating AMI role for the API: %w", err)
 	}
 
 	if _, err = getOrCreateIntegration(client, api, root, fn, role, conf); err != nil {
-		return fmt.Errorf("error creating integration for root resource: %v", err)
+		return fmt.Errorf("error creating integration for root resource: %w", err)
 	}
 
 	if _, err = getOrCreateIntegration(client, api, proxy, fn, role, conf); err != nil {
-		return fmt.Errorf("error creating integration for proxy resource: %v", err)
+		return fmt.Errorf("error creating integration for proxy resource: %w", err)
+	}
+
+	for _, path := range conf.Auth.Public {
+		if err = getOrCreatePublicPath(client, api, path, fn, role, conf); err != nil {
+			return fmt.Errorf("error creating public path '%v': %w", path, err)
+		}
+	}
+
+	if err = restrictStalePublicPaths(client, api, fn, authorizer, conf); err != nil {
+		return fmt.Errorf("error restricting stale public paths: %w", err)
+	}
+
+	for _, route := range conf.Routes {
+		if err = getOrCreateRoute(client, api, route, routes[route.Name], authorizer, role, conf); err != nil {
+			return fmt.Errorf("error creating route '%v': %w", route.Path, err)
+		}
+	}
+
+	if err = removeStaleRoutes(client, api, conf); err != nil {
+		return fmt.Errorf("error removing stale routes: %w", err)
+	}
+
+	if err = getOrCreateCORS(client, api, root, conf); err != nil {
+		return fmt.Errorf("error setting up CORS for root resource: %w", err)
+	}
+
+	if err = getOrCreateCORS(client, api, proxy, conf); err != nil {
+		return fmt.Errorf("error setting up CORS for proxy resource: %w", err)
+	}
+
+	if err = createOrUpdateCORSResponses(client, api, conf); err != nil {
+		return fmt.Errorf("error adding CORS headers to gateway responses: %w", err)
+	}
+
+	if staticEnabled(conf) {
+		if err = getOrCreateStatic(client, api, fn, authorizer, conf); err != nil {
+			return fmt.Errorf("error setting up static files: %w", err)
+		}
+	}
+
+	if err = deployAPI(client, api, stageVariables(fn, conf), conf); err != nil {
+		return fmt.Errorf("error deploying API: %w", err)
+	}
+
+	if err = updateStage(client, api, conf); err != nil {
+		return fmt.Errorf("error updating stage settings: %w", err)
+	}
+
+	if err = tagAPIResource(client, apiARN(api, conf), resourceTags(conf), conf); err != nil {
+		return fmt.Errorf("error tagging API: %w", err)
+	}
+
+	if err = tagAPIResource(client, stageARN(api, conf), environmentTags(fn, conf), conf); err != nil {
+		return fmt.Errorf("error tagging stage: %w", err)
+	}
+
+	if err = getOrCreateUsagePlan(client, api, conf); err != nil {
+		return fmt.Errorf("error creating usage plan: %w", err)
 	}
 
-	return deployAPI(client, api, conf)
+	return nil
 }
 
 func GetInvokeUrl(conf *Config) (string, error) {
@@ -64,8 +135,15 @@ func GetInvokeUrl(conf *Config) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if api == nil {
+		return "", &NotFoundError{Err: fmt.Errorf("API '%v' doesn't exist, deploy it first", apiName(conf))}
+	}
 
-	return fmt.Sprintf("https://%v.execute-api.%v.amazonaws.com/%v", *api.Id, conf.Region, conf.Environment), nil
+	return invokeURL(api, conf), nil
+}
+
+func invokeURL(api *ag.RestApi, conf *Config) string {
+	return fmt.Sprintf("https://%v.execute-api.%v.amazonaws.com/%v", *api.Id, conf.Region, conf.Environment)
 }
 
 func getOrCreateRestAPI(client *ag.APIGateway, conf *Config) (*ag.RestApi, error) {
@@ -75,7 +153,7 @@ func getOrCreateRestAPI(client *ag.APIGateway, conf *Config) (*ag.RestApi, error
 	}
 
 	if api == nil {
-		fmt.Printf("Creating API Gateway named '%v'\n", apiName(conf))
+		created(conf, "API", apiName(conf), "")
 		return createAPI(client, conf)
 	}
 
@@ -102,6 +180,7 @@ func createAPI(client *ag.APIGateway, conf *Config) (*ag.RestApi, error) {
 	return client.CreateRestApi(&ag.CreateRestApiInput{
 		Name:        aws.String(apiName(conf)),
 		Description: aws.String(conf.Description),
+		Tags:        aws.StringMap(resourceTags(conf)),
 	})
 }
 
@@ -112,23 +191,122 @@ func getOrCreateProxy(client *ag.APIGateway, api *ag.RestApi, conf *Config) (*ag
 	}
 
 	if proxy == nil {
-		fmt.Printf("Creating proxy resource on '%v'\n", apiName(conf))
+		created(conf, "resource", "/"+proxyPath, "")
 		return createProxy(client, api, conf)
 	}
 
 	return proxy, nil
 }
 
+// getOrCreatePublicPath wires an explicit resource at path to the function, without
+// the authorizer and API key requirement that apply to the rest of the API.
+func getOrCreatePublicPath(
+	client *ag.APIGateway,
+	api *ag.RestApi,
+	path string,
+	fn *lambda.FunctionConfiguration,
+	role *iam.Role,
+	conf *Config) error {
+
+	resource, err := getOrCreateResourcePath(client, api, path, conf)
+	if err != nil {
+		return err
+	}
+
+	if _, err = getOrCreateMethod(client, api, resource, methodAccess(*resource.Path, nil, conf), conf); err != nil {
+		return err
+	}
+
+	if _, err = getOrCreateIntegration(client, api, resource, fn, role, conf); err != nil {
+		return err
+	}
+
+	return getOrCreateCORS(client, api, resource, conf)
+}
+
+// restrictStalePublicPaths puts the API's authorization back on the resources of paths
+// that were removed from auth.public. They are recognized by their integration with the
+// app function, which only the root, the proxy and public paths have.
+func restrictStalePublicPaths(
+	client *ag.APIGateway,
+	api *ag.RestApi,
+	fn *lambda.FunctionConfiguration,
+	authorizer *ag.Authorizer,
+	conf *Config) error {
+
+	resources, err := getResources(client, api)
+	if err != nil {
+		return err
+	}
+
+	uri := rewriteLambdaARN(*fn.FunctionArn, conf)
+	for _, res := range resources {
+		path := aws.StringValue(res.Path)
+		if path == "/" || path == "/"+proxyPath || isPublicPath(path, conf) {
+			continue
+		}
+
+		integ, err := getIntegration(api, res, client)
+		if err != nil {
+			return err
+		}
+
+		if integ == nil || aws.StringValue(integ.Uri) != uri {
+			continue
+		}
+
+		if _, err = getOrCreateMethod(client, api, res, methodAccess(path, authorizer, conf), conf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getOrCreateResourcePath returns the resource at path, creating it and any missing parents.
+func getOrCreateResourcePath(client *ag.APIGateway, api *ag.RestApi, path string, conf *Config) (*ag.Resource, error) {
+	parent, err := getResource(client, api, "")
+	if err != nil {
+		return nil, err
+	}
+
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return parent, nil
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		resource, err := getResource(client, api, strings.Join(segments[:i+1], "/"))
+		if err != nil {
+			return nil, err
+		}
+
+		if resource == nil {
+			created(conf, "resource", "/"+strings.Join(segments[:i+1], "/"), "")
+			resource, err = client.CreateResource(&ag.CreateResourceInput{
+				RestApiId: api.Id,
+				ParentId:  parent.Id,
+				PathPart:  aws.String(segment),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		parent = resource
+	}
+
+	return parent, nil
+}
+
 func getResource(client *ag.APIGateway, api *ag.RestApi, path string) (*ag.Resource, error) {
-	resources, err := client.GetResources(&ag.GetResourcesInput{
-		RestApiId: api.Id,
-		Limit:     aws.Int64(100),
-	})
+	resources, err := getResources(client, api)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, res := range resources.Items {
+	for _, res := range resources {
 		if *res.Path == fmt.Sprintf("/%v", path) {
 			return res, nil
 		}
@@ -137,6 +315,19 @@ func getResource(client *ag.APIGateway, api *ag.RestApi, path string) (*ag.Resou
 	return nil, nil
 }
 
+func getResources(client *ag.APIGateway, api *ag.RestApi) ([]*ag.Resource, error) {
+	var resources []*ag.Resource
+	err := client.GetResourcesPages(&ag.GetResourcesInput{
+		RestApiId: api.Id,
+		Limit:     aws.Int64(500),
+	}, func(page *ag.GetResourcesOutput, last bool) bool {
+		resources = append(resources, page.Items...)
+		return true
+	})
+
+	return resources, err
+}
+
 func createProxy(client *ag.APIGateway, api *ag.RestApi, conf *Config) (*ag.Resource, error) {
 	root, err := getResource(client, api, "")
 	if err != nil {
@@ -154,29 +345,34 @@ func createProxy(client *ag.APIGateway, api *ag.RestApi, conf *Config) (*ag.Reso
 	})
 }
 
-func getOrCreateMethod(client *ag.APIGateway, api *ag.RestApi, resource *ag.Resource) (*ag.Method, error) {
-	method, err := getMethod(client, api, resource)
+func getOrCreateMethod(client *ag.APIGateway, api *ag.RestApi, resource *ag.Resource, acc access, conf *Config) (*ag.Method, error) {
+	method, err := getMethod(client, api, resource, "ANY")
 	if err != nil {
 		return nil, err
 	}
 
 	if method == nil {
-		fmt.Printf("Creating 'ANY' method on '%v'\n", *resource.Path)
-		return createMethod(client, api, resource)
+		created(conf, "method", "ANY "+*resource.Path, "")
+		return createMethod(client, api, resource, acc)
+	}
+
+	if patches := methodPatches(method, acc); len(patches) > 0 {
+		updated(conf, "method", "ANY "+*resource.Path, "")
+		return updateMethod(client, api, resource, patches)
 	}
 
 	return method, nil
 }
 
-func getMethod(client *ag.APIGateway, api *ag.RestApi, proxy *ag.Resource) (*ag.Method, error) {
+func getMethod(client *ag.APIGateway, api *ag.RestApi, proxy *ag.Resource, httpMethod string) (*ag.Method, error) {
 	method, err := client.GetMethod(&ag.GetMethodInput{
 		RestApiId:  api.Id,
 		ResourceId: proxy.Id,
-		HttpMethod: aws.String("ANY"),
+		HttpMethod: aws.String(httpMethod),
 	})
 
 	if err != nil {
-		if strings.Contains(err.Error(), "NotFound") {
+		if isNotFound(err) {
 			return nil, nil
 		}
 		return nil, err
@@ -185,15 +381,52 @@ func getMethod(client *ag.APIGateway, api *ag.RestApi, proxy *ag.Resource) (*ag.
 	return method, nil
 }
 
-func createMethod(client *ag.APIGateway, api *ag.RestApi, proxy *ag.Resource) (*ag.Method, error) {
+func createMethod(client *ag.APIGateway, api *ag.RestApi, proxy *ag.Resource, acc access) (*ag.Method, error) {
 	return client.PutMethod(&ag.PutMethodInput{
 		RestApiId:         api.Id,
 		ResourceId:        proxy.Id,
 		HttpMethod:        aws.String("ANY"),
-		AuthorizationType: aws.String("NONE"),
+		AuthorizationType: aws.String(acc.authorizationType),
+		AuthorizerId:      acc.authorizerID,
+		ApiKeyRequired:    aws.Bool(acc.apiKeyRequired),
 	})
 }
 
+func updateMethod(client *ag.APIGateway, api *ag.RestApi, proxy *ag.Resource, patches []*ag.PatchOperation) (*ag.Method, error) {
+	return client.UpdateMethod(&ag.UpdateMethodInput{
+		RestApiId:       api.Id,
+		ResourceId:      proxy.Id,
+		HttpMethod:      aws.String("ANY"),
+		PatchOperations: patches,
+	})
+}
+
+// methodPatches lists the changes needed to bring an existing method in line with the config.
+func methodPatches(method *ag.Method, acc access) []*ag.PatchOperation {
+	var patches []*ag.PatchOperation
+	replace := func(path, value string) {
+		patches = append(patches, &ag.PatchOperation{
+			Op:    aws.String(ag.OpReplace),
+			Path:  aws.String(path),
+			Value: aws.String(value),
+		})
+	}
+
+	if aws.StringValue(method.AuthorizationType) != acc.authorizationType {
+		replace("/authorizationType", acc.authorizationType)
+	}
+
+	if acc.authorizerID != nil && aws.StringValue(method.AuthorizerId) != *acc.authorizerID {
+		replace("/authorizerId", *acc.authorizerID)
+	}
+
+	if aws.BoolValue(method.ApiKeyRequired) != acc.apiKeyRequired {
+		replace("/apiKeyRequired", strconv.FormatBool(acc.apiKeyRequired))
+	}
+
+	return patches
+}
+
 func getOrCreateIntegration(
 	client *ag.APIGateway,
 	api *ag.RestApi,
@@ -209,7 +442,12 @@ func getOrCreateIntegration(
 	}
 
 	if integ == nil {
-		fmt.Printf("Creating integration between Lambda and API on '%v'\n", *resource.Path)
+		created(conf, "integration", "ANY "+*resource.Path, "")
+		return createIntegration(client, api, resource, fn, role, conf)
+	}
+
+	if aws.StringValue(integ.Uri) != rewriteLambdaARN(*fn.FunctionArn, conf) || aws.StringValue(integ.Credentials) != *role.Arn {
+		updated(conf, "integration", "ANY "+*resource.Path, "")
 		return createIntegration(client, api, resource, fn, role, conf)
 	}
 
@@ -224,7 +462,7 @@ func getIntegration(api *ag.RestApi, proxy *ag.Resource, client *ag.APIGateway)
 	})
 
 	if err != nil {
-		if strings.Contains(err.Error(), "NotFound") {
+		if isNotFound(err) {
 			return nil, nil
 		}
 		return nil, err
@@ -243,11 +481,11 @@ func createIntegration(
 	return client.PutIntegration(&ag.PutIntegrationInput{
 		HttpMethod:            aws.String("ANY"),
 		IntegrationHttpMethod: aws.String("POST"),
-		Type:        aws.String(ag.IntegrationTypeAwsProxy),
-		Credentials: role.Arn,
-		RestApiId:   api.Id,
-		ResourceId:  proxy.Id,
-		Uri:         aws.String(rewriteLambdaARN(*fn.FunctionArn, conf)),
+		Type:                  aws.String(ag.IntegrationTypeAwsProxy),
+		Credentials:           role.Arn,
+		RestApiId:             api.Id,
+		ResourceId:            proxy.Id,
+		Uri:                   aws.String(rewriteLambdaARN(*fn.FunctionArn, conf)),
 	})
 }
 
@@ -260,7 +498,9 @@ func rewriteLambdaARN(arn string, conf *Config) string {
 	)
 }
 
-func deployAPI(client *ag.APIGateway, api *ag.RestApi, conf *Config) error {
+// stageVariables returns the variables of the current environment, along with those
+// launch itself relies on.
+func stageVariables(fn *lambda.FunctionConfiguration, conf *Config) map[string]*string {
 	vars := map[string]*string{
 		"environment": aws.String(conf.Environment),
 	}
@@ -272,6 +512,14 @@ func deployAPI(client *ag.APIGateway, api *ag.RestApi, conf *Config) error {
 		}
 	}
 
+	if staticEnabled(conf) {
+		vars[staticBucketVariable] = aws.String(staticBucketName(fn, conf))
+	}
+
+	return vars
+}
+
+func deployAPI(client *ag.APIGateway, api *ag.RestApi, vars map[string]*string, conf *Config) error {
 	_, err := client.CreateDeployment(&ag.CreateDeploymentInput{
 		Description: aws.String(time.Now().Format(time.RFC1123Z)),
 		StageName:   aws.String(conf.Environment),
@@ -0,0 +1,324 @@
+package launch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+// CreateAPIKey creates an API key for the named client and attaches it to the usage plan
+// of the current environment. The value of the key is only available on the returned key.
+func CreateAPIKey(clientName string, conf *Config) (*ag.ApiKey, error) {
+	client := ag.New(conf.Session)
+
+	plan, err := getUsagePlan(client, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if plan == nil {
+		return nil, fmt.Errorf("there is no usage plan for '%v', deploy the environment before creating keys", conf.Environment)
+	}
+
+	existing, err := getAPIKey(client, clientName, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		return nil, fmt.Errorf("client '%v' already has a key in '%v', revoke it first", clientName, conf.Environment)
+	}
+
+	key, err := client.CreateApiKey(&ag.CreateApiKeyInput{
+		Name:        aws.String(apiKeyName(clientName, conf)),
+		Description: aws.String(fmt.Sprintf("%v access for %v", conf.Environment, clientName)),
+		Enabled:     aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = client.CreateUsagePlanKey(&ag.CreateUsagePlanKeyInput{
+		UsagePlanId: plan.Id,
+		KeyId:       key.Id,
+		KeyType:     aws.String("API_KEY"),
+	})
+	if err != nil {
+		// A key outside the plan can't be listed or revoked, so it isn't left behind.
+		if _, delErr := client.DeleteApiKey(&ag.DeleteApiKeyInput{ApiKey: key.Id}); delErr != nil {
+			return nil, fmt.Errorf("created key '%v', but couldn't add it to the usage plan (%v) or delete it: %w", *key.Name, err, delErr)
+		}
+		return nil, fmt.Errorf("couldn't add key '%v' to the usage plan: %w", *key.Name, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns the keys belonging to the current environment, without their values.
+// Key names only tell environments apart up to a dash, so keys are matched to the
+// environment through its usage plan.
+func ListAPIKeys(conf *Config) ([]*ag.ApiKey, error) {
+	client := ag.New(conf.Session)
+
+	ids, err := usagePlanKeyIDs(client, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*ag.ApiKey
+	err = client.GetApiKeysPages(&ag.GetApiKeysInput{
+		NameQuery: aws.String(apiKeyName("", conf)),
+		Limit:     aws.Int64(500),
+	}, func(page *ag.GetApiKeysOutput, last bool) bool {
+		for _, key := range page.Items {
+			if strings.HasPrefix(*key.Name, apiKeyName("", conf)) && ids[*key.Id] {
+				keys = append(keys, key)
+			}
+		}
+		return true
+	})
+
+	return keys, err
+}
+
+// RevokeAPIKey deletes the key belonging to the named client.
+func RevokeAPIKey(clientName string, conf *Config) error {
+	client := ag.New(conf.Session)
+
+	key, err := getAPIKey(client, clientName, conf)
+	if err != nil {
+		return err
+	}
+
+	if key == nil {
+		return fmt.Errorf("client '%v' has no key in '%v'", clientName, conf.Environment)
+	}
+
+	_, err = client.DeleteApiKey(&ag.DeleteApiKeyInput{
+		ApiKey: key.Id,
+	})
+	return err
+}
+
+// APIKeyClient returns the client name a key was created for.
+func APIKeyClient(key *ag.ApiKey, conf *Config) string {
+	return strings.TrimPrefix(*key.Name, apiKeyName("", conf))
+}
+
+func getAPIKey(client *ag.APIGateway, clientName string, conf *Config) (*ag.ApiKey, error) {
+	ids, err := usagePlanKeyIDs(client, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := client.GetApiKeys(&ag.GetApiKeysInput{
+		NameQuery: aws.String(apiKeyName(clientName, conf)),
+		Limit:     aws.Int64(500),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys.Items {
+		if *key.Name == apiKeyName(clientName, conf) && ids[*key.Id] {
+			return key, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// usagePlanKeyIDs returns the IDs of the keys attached to the usage plan of the current
+// environment.
+func usagePlanKeyIDs(client *ag.APIGateway, conf *Config) (map[string]bool, error) {
+	ids := map[string]bool{}
+
+	plan, err := getUsagePlan(client, conf)
+	if err != nil || plan == nil {
+		return ids, err
+	}
+
+	err = client.GetUsagePlanKeysPages(&ag.GetUsagePlanKeysInput{
+		UsagePlanId: plan.Id,
+		Limit:       aws.Int64(500),
+	}, func(page *ag.GetUsagePlanKeysOutput, last bool) bool {
+		for _, key := range page.Items {
+			ids[*key.Id] = true
+		}
+		return true
+	})
+
+	return ids, err
+}
+
+// getOrCreateUsagePlan attaches the usage plan of the current environment to its stage.
+// Once API keys are no longer configured, the plan is detached from the stage but kept,
+// along with its keys, in case they are configured again.
+func getOrCreateUsagePlan(client *ag.APIGateway, api *ag.RestApi, conf *Config) error {
+	plan, err := getUsagePlan(client, conf)
+	if err != nil {
+		return err
+	}
+
+	settings, defined := conf.APIKeys.Plans[conf.Environment]
+	if !defined && !conf.APIKeys.Required {
+		if plan == nil || !planAttached(plan, api, conf) {
+			return nil
+		}
+
+		updated(conf, "usage plan", usagePlanName(conf), "detached from the stage")
+		return detachUsagePlan(client, plan, api, conf)
+	}
+
+	if plan == nil {
+		created(conf, "usage plan", usagePlanName(conf), "")
+		_, err = client.CreateUsagePlan(&ag.CreateUsagePlanInput{
+			Name:        aws.String(usagePlanName(conf)),
+			Description: aws.String(fmt.Sprintf("API keys for the '%v' stage of '%v'", conf.Environment, apiName(conf))),
+			ApiStages: []*ag.ApiStage{
+				{ApiId: api.Id, Stage: aws.String(conf.Environment)},
+			},
+			Throttle: throttleSettings(settings),
+			Quota:    quotaSettings(settings),
+		})
+		return err
+	}
+
+	if patches := usagePlanPatches(plan, api, settings, conf); len(patches) > 0 {
+		updated(conf, "usage plan", usagePlanName(conf), "")
+		_, err = client.UpdateUsagePlan(&ag.UpdateUsagePlanInput{
+			UsagePlanId:     plan.Id,
+			PatchOperations: patches,
+		})
+	}
+
+	return err
+}
+
+// getUsagePlan returns the usage plan of the current environment. Besides its name, the
+// plan must only be attached to stages of the environment.
+func getUsagePlan(client *ag.APIGateway, conf *Config) (*ag.UsagePlan, error) {
+	var plan *ag.UsagePlan
+	err := client.GetUsagePlansPages(&ag.GetUsagePlansInput{
+		Limit: aws.Int64(500),
+	}, func(page *ag.GetUsagePlansOutput, last bool) bool {
+		for _, p := range page.Items {
+			if *p.Name == usagePlanName(conf) && planOfEnvironment(p, conf) {
+				plan = p
+				return false
+			}
+		}
+		return true
+	})
+
+	return plan, err
+}
+
+func planOfEnvironment(plan *ag.UsagePlan, conf *Config) bool {
+	for _, stage := range plan.ApiStages {
+		if aws.StringValue(stage.Stage) != conf.Environment {
+			return false
+		}
+	}
+	return true
+}
+
+func planAttached(plan *ag.UsagePlan, api *ag.RestApi, conf *Config) bool {
+	for _, stage := range plan.ApiStages {
+		if aws.StringValue(stage.ApiId) == *api.Id && aws.StringValue(stage.Stage) == conf.Environment {
+			return true
+		}
+	}
+	return false
+}
+
+// detachUsagePlan removes the stage of the current environment from the plan.
+func detachUsagePlan(client *ag.APIGateway, plan *ag.UsagePlan, api *ag.RestApi, conf *Config) error {
+	_, err := client.UpdateUsagePlan(&ag.UpdateUsagePlanInput{
+		UsagePlanId: plan.Id,
+		PatchOperations: []*ag.PatchOperation{
+			{
+				Op:    aws.String(ag.OpRemove),
+				Path:  aws.String("/apiStages"),
+				Value: aws.String(fmt.Sprintf("%v:%v", *api.Id, conf.Environment)),
+			},
+		},
+	})
+	return err
+}
+
+func usagePlanPatches(plan *ag.UsagePlan, api *ag.RestApi, settings UsagePlan, conf *Config) []*ag.PatchOperation {
+	var patches []*ag.PatchOperation
+	replace := func(path, value string) {
+		patches = append(patches, &ag.PatchOperation{
+			Op:    aws.String(ag.OpReplace),
+			Path:  aws.String(path),
+			Value: aws.String(value),
+		})
+	}
+
+	if !planAttached(plan, api, conf) {
+		patches = append(patches, &ag.PatchOperation{
+			Op:    aws.String(ag.OpAdd),
+			Path:  aws.String("/apiStages"),
+			Value: aws.String(fmt.Sprintf("%v:%v", *api.Id, conf.Environment)),
+		})
+	}
+
+	if throttle := throttleSettings(settings); throttle != nil {
+		if plan.Throttle == nil || aws.Float64Value(plan.Throttle.RateLimit) != settings.Rate {
+			replace("/throttle/rateLimit", strconv.FormatFloat(settings.Rate, 'f', -1, 64))
+		}
+		if plan.Throttle == nil || aws.Int64Value(plan.Throttle.BurstLimit) != settings.Burst {
+			replace("/throttle/burstLimit", strconv.FormatInt(settings.Burst, 10))
+		}
+	} else if plan.Throttle != nil {
+		patches = append(patches, &ag.PatchOperation{Op: aws.String(ag.OpRemove), Path: aws.String("/throttle")})
+	}
+
+	if quota := quotaSettings(settings); quota != nil {
+		if plan.Quota == nil || aws.Int64Value(plan.Quota.Limit) != settings.Quota {
+			replace("/quota/limit", strconv.FormatInt(settings.Quota, 10))
+		}
+		if plan.Quota == nil || aws.StringValue(plan.Quota.Period) != *quota.Period {
+			replace("/quota/period", *quota.Period)
+		}
+	} else if plan.Quota != nil {
+		patches = append(patches, &ag.PatchOperation{Op: aws.String(ag.OpRemove), Path: aws.String("/quota")})
+	}
+
+	return patches
+}
+
+func throttleSettings(settings UsagePlan) *ag.ThrottleSettings {
+	if settings.Rate == 0 && settings.Burst == 0 {
+		return nil
+	}
+
+	return &ag.ThrottleSettings{
+		RateLimit:  aws.Float64(settings.Rate),
+		BurstLimit: aws.Int64(settings.Burst),
+	}
+}
+
+func quotaSettings(settings UsagePlan) *ag.QuotaSettings {
+	if settings.Quota == 0 {
+		return nil
+	}
+
+	return &ag.QuotaSettings{
+		Limit:  aws.Int64(settings.Quota),
+		Period: aws.String(strings.ToUpper(settings.Period)),
+	}
+}
+
+func usagePlanName(conf *Config) string {
+	return fmt.Sprintf("%v-%v-plan", conf.Name, conf.Environment)
+}
+
+func apiKeyName(clientName string, conf *Config) string {
+	return fmt.Sprintf("%v-%v-%v", conf.Name, conf.Environment, clientName)
+}
@@ -0,0 +1,142 @@
+package launch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+// patchPaths lists the operations and paths of patches, for comparing them in tests.
+func patchPaths(patches []*ag.PatchOperation) []string {
+	var paths []string
+	for _, p := range patches {
+		paths = append(paths, *p.Op+" "+*p.Path)
+	}
+	return paths
+}
+
+func TestUsagePlanPatches(t *testing.T) {
+	api := &ag.RestApi{Id: aws.String("api")}
+	attached := []*ag.ApiStage{{ApiId: aws.String("api"), Stage: aws.String("dev")}}
+
+	tests := []struct {
+		name     string
+		plan     *ag.UsagePlan
+		settings UsagePlan
+		want     []string
+	}{
+		{
+			name: "unchanged",
+			plan: &ag.UsagePlan{
+				ApiStages: attached,
+				Throttle:  &ag.ThrottleSettings{RateLimit: aws.Float64(50), BurstLimit: aws.Int64(100)},
+				Quota:     &ag.QuotaSettings{Limit: aws.Int64(1000), Period: aws.String("MONTH")},
+			},
+			settings: UsagePlan{Rate: 50, Burst: 100, Quota: 1000, Period: "month"},
+			want:     nil,
+		},
+		{
+			name:     "detached",
+			plan:     &ag.UsagePlan{},
+			settings: UsagePlan{},
+			want:     []string{"add /apiStages"},
+		},
+		{
+			name:     "attached to another stage",
+			plan:     &ag.UsagePlan{ApiStages: []*ag.ApiStage{{ApiId: aws.String("api"), Stage: aws.String("prod")}}},
+			settings: UsagePlan{},
+			want:     []string{"add /apiStages"},
+		},
+		{
+			name: "changed limits",
+			plan: &ag.UsagePlan{
+				ApiStages: attached,
+				Throttle:  &ag.ThrottleSettings{RateLimit: aws.Float64(50), BurstLimit: aws.Int64(100)},
+				Quota:     &ag.QuotaSettings{Limit: aws.Int64(1000), Period: aws.String("MONTH")},
+			},
+			settings: UsagePlan{Rate: 50, Burst: 200, Quota: 1000, Period: "DAY"},
+			want:     []string{"replace /throttle/burstLimit", "replace /quota/period"},
+		},
+		{
+			name:     "new limits",
+			plan:     &ag.UsagePlan{ApiStages: attached},
+			settings: UsagePlan{Rate: 50, Burst: 100, Quota: 1000, Period: "WEEK"},
+			want: []string{
+				"replace /throttle/rateLimit",
+				"replace /throttle/burstLimit",
+				"replace /quota/limit",
+				"replace /quota/period",
+			},
+		},
+		{
+			name: "removed limits",
+			plan: &ag.UsagePlan{
+				ApiStages: attached,
+				Throttle:  &ag.ThrottleSettings{RateLimit: aws.Float64(50), BurstLimit: aws.Int64(100)},
+				Quota:     &ag.QuotaSettings{Limit: aws.Int64(1000), Period: aws.String("MONTH")},
+			},
+			settings: UsagePlan{},
+			want:     []string{"remove /throttle", "remove /quota"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &Config{Name: "app", Environment: "dev"}
+
+			got := patchPaths(usagePlanPatches(tt.plan, api, tt.settings, conf))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanOfEnvironment(t *testing.T) {
+	conf := &Config{Name: "app", Environment: "dev"}
+
+	tests := []struct {
+		stages []string
+		want   bool
+	}{
+		{nil, true},
+		{[]string{"dev"}, true},
+		{[]string{"dev-2"}, false},
+		{[]string{"dev", "prod"}, false},
+	}
+
+	for _, tt := range tests {
+		plan := &ag.UsagePlan{}
+		for _, stage := range tt.stages {
+			plan.ApiStages = append(plan.ApiStages, &ag.ApiStage{ApiId: aws.String("api"), Stage: aws.String(stage)})
+		}
+
+		if got := planOfEnvironment(plan, conf); got != tt.want {
+			t.Errorf("expected a plan attached to %v to belong to dev: %v, got %v", tt.stages, tt.want, got)
+		}
+	}
+}
+
+func TestValidateUsagePlanThrottling(t *testing.T) {
+	tests := []struct {
+		plan  UsagePlan
+		valid bool
+	}{
+		{UsagePlan{}, true},
+		{UsagePlan{Rate: 50, Burst: 100}, true},
+		{UsagePlan{Rate: 50}, false},
+		{UsagePlan{Burst: 100}, false},
+	}
+
+	for _, tt := range tests {
+		conf := &Config{Name: "app", Region: "us-east-1", Port: 8000, APIKeys: APIKeys{
+			Plans: map[string]UsagePlan{"dev": tt.plan},
+		}}
+
+		if errs := ValidateConfig(conf); (len(errs) == 0) != tt.valid {
+			t.Errorf("expected %+v to be valid: %v, got %v", tt.plan, tt.valid, errs)
+		}
+	}
+}
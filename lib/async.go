@@ -0,0 +1,143 @@
+package launch
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// destinationActions are the actions a function needs to send records to a destination,
+// keyed by the service of its ARN.
+var destinationActions = map[string]string{
+	"sqs":    "sqs:SendMessage",
+	"sns":    "sns:Publish",
+	"lambda": "lambda:InvokeFunction",
+	"events": "events:PutEvents",
+}
+
+func asyncConfigured(conf *Config) bool {
+	return conf.Async.MaxRetries != nil || conf.Async.MaxEventAge > 0 || conf.Async.OnFailure != "" || conf.Async.OnSuccess != ""
+}
+
+// Lambda retries async invocations twice, and keeps events for six hours, unless told
+// otherwise.
+const (
+	defaultAsyncRetries  = 2
+	defaultAsyncEventAge = 21600
+)
+
+// putAsyncConfig applies the retry and destination settings to the alias of the current
+// environment, or resets them to the defaults of Lambda when none are configured. The
+// settings are only written when they differ from the current ones.
+func putAsyncConfig(client *lambda.Lambda, conf *Config) error {
+	current, err := client.GetFunctionEventInvokeConfig(&lambda.GetFunctionEventInvokeConfigInput{
+		FunctionName: aws.String(conf.Name),
+		Qualifier:    aws.String(conf.Environment),
+	})
+	if isNotFound(err) {
+		current, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !asyncConfigured(conf) {
+		if current == nil {
+			return nil
+		}
+
+		updated(conf, "alias", conf.Environment, "async settings reset")
+		_, err = client.DeleteFunctionEventInvokeConfig(&lambda.DeleteFunctionEventInvokeConfigInput{
+			FunctionName: aws.String(conf.Name),
+			Qualifier:    aws.String(conf.Environment),
+		})
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	input := &lambda.PutFunctionEventInvokeConfigInput{
+		FunctionName:         aws.String(conf.Name),
+		Qualifier:            aws.String(conf.Environment),
+		MaximumRetryAttempts: conf.Async.MaxRetries,
+		DestinationConfig:    &lambda.DestinationConfig{},
+	}
+
+	if conf.Async.MaxEventAge > 0 {
+		input.MaximumEventAgeInSeconds = aws.Int64(conf.Async.MaxEventAge)
+	}
+	if conf.Async.OnFailure != "" {
+		input.DestinationConfig.OnFailure = &lambda.OnFailure{Destination: aws.String(conf.Async.OnFailure)}
+	}
+	if conf.Async.OnSuccess != "" {
+		input.DestinationConfig.OnSuccess = &lambda.OnSuccess{Destination: aws.String(conf.Async.OnSuccess)}
+	}
+
+	if current != nil && !asyncConfigChanged(current, input) {
+		return nil
+	}
+
+	updated(conf, "alias", conf.Environment, "async settings")
+	_, err = client.PutFunctionEventInvokeConfig(input)
+	return err
+}
+
+// asyncConfigChanged compares the current settings of an alias to the desired ones.
+// Settings left out on either side count as the defaults of Lambda.
+func asyncConfigChanged(current *lambda.GetFunctionEventInvokeConfigOutput, desired *lambda.PutFunctionEventInvokeConfigInput) bool {
+	retries := func(v *int64) int64 {
+		if v == nil {
+			return defaultAsyncRetries
+		}
+		return *v
+	}
+	age := func(v *int64) int64 {
+		if v == nil {
+			return defaultAsyncEventAge
+		}
+		return *v
+	}
+	destinations := func(d *lambda.DestinationConfig) (string, string) {
+		if d == nil {
+			return "", ""
+		}
+		var onFailure, onSuccess string
+		if d.OnFailure != nil {
+			onFailure = aws.StringValue(d.OnFailure.Destination)
+		}
+		if d.OnSuccess != nil {
+			onSuccess = aws.StringValue(d.OnSuccess.Destination)
+		}
+		return onFailure, onSuccess
+	}
+
+	currentFailure, currentSuccess := destinations(current.DestinationConfig)
+	desiredFailure, desiredSuccess := destinations(desired.DestinationConfig)
+
+	return retries(current.MaximumRetryAttempts) != retries(desired.MaximumRetryAttempts) ||
+		age(current.MaximumEventAgeInSeconds) != age(desired.MaximumEventAgeInSeconds) ||
+		currentFailure != desiredFailure ||
+		currentSuccess != desiredSuccess
+}
+
+// asyncDestinations returns the ARNs the function sends records to, including the DLQ.
+func asyncDestinations(conf *Config) []string {
+	var arns []string
+	for _, arn := range []string{conf.Async.OnFailure, conf.Async.OnSuccess, conf.Async.DLQ} {
+		if arn != "" {
+			arns = append(arns, arn)
+		}
+	}
+	return arns
+}
+
+// arnService returns the service of an ARN, e.g. 'sqs' for arn:aws:sqs:...
+func arnService(arn string) string {
+	parts := strings.SplitN(arn, ":", 4)
+	if len(parts) < 4 {
+		return ""
+	}
+	return parts[2]
+}
@@ -0,0 +1,80 @@
+package launch
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestAsyncConfigChanged(t *testing.T) {
+	queue := "arn:aws:sqs:us-east-1:123456789012:failed"
+
+	tests := []struct {
+		name    string
+		current *lambda.GetFunctionEventInvokeConfigOutput
+		desired *lambda.PutFunctionEventInvokeConfigInput
+		changed bool
+	}{
+		{
+			name: "same settings",
+			current: &lambda.GetFunctionEventInvokeConfigOutput{
+				MaximumRetryAttempts: aws.Int64(0),
+				DestinationConfig:    &lambda.DestinationConfig{OnFailure: &lambda.OnFailure{Destination: aws.String(queue)}},
+			},
+			desired: &lambda.PutFunctionEventInvokeConfigInput{
+				MaximumRetryAttempts: aws.Int64(0),
+				DestinationConfig:    &lambda.DestinationConfig{OnFailure: &lambda.OnFailure{Destination: aws.String(queue)}},
+			},
+			changed: false,
+		},
+		{
+			name: "defaults reported by Lambda",
+			current: &lambda.GetFunctionEventInvokeConfigOutput{
+				MaximumRetryAttempts:     aws.Int64(2),
+				MaximumEventAgeInSeconds: aws.Int64(21600),
+				DestinationConfig:        &lambda.DestinationConfig{OnFailure: &lambda.OnFailure{}, OnSuccess: &lambda.OnSuccess{}},
+			},
+			desired: &lambda.PutFunctionEventInvokeConfigInput{
+				DestinationConfig: &lambda.DestinationConfig{},
+			},
+			changed: false,
+		},
+		{
+			name:    "changed retries",
+			current: &lambda.GetFunctionEventInvokeConfigOutput{MaximumRetryAttempts: aws.Int64(2)},
+			desired: &lambda.PutFunctionEventInvokeConfigInput{MaximumRetryAttempts: aws.Int64(1)},
+			changed: true,
+		},
+		{
+			name:    "changed event age",
+			current: &lambda.GetFunctionEventInvokeConfigOutput{},
+			desired: &lambda.PutFunctionEventInvokeConfigInput{MaximumEventAgeInSeconds: aws.Int64(60)},
+			changed: true,
+		},
+		{
+			name:    "new destination",
+			current: &lambda.GetFunctionEventInvokeConfigOutput{},
+			desired: &lambda.PutFunctionEventInvokeConfigInput{
+				DestinationConfig: &lambda.DestinationConfig{OnSuccess: &lambda.OnSuccess{Destination: aws.String(queue)}},
+			},
+			changed: true,
+		},
+		{
+			name: "removed destination",
+			current: &lambda.GetFunctionEventInvokeConfigOutput{
+				DestinationConfig: &lambda.DestinationConfig{OnFailure: &lambda.OnFailure{Destination: aws.String(queue)}},
+			},
+			desired: &lambda.PutFunctionEventInvokeConfigInput{DestinationConfig: &lambda.DestinationConfig{}},
+			changed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := asyncConfigChanged(tt.current, tt.desired); got != tt.changed {
+				t.Errorf("expected changed to be %v, got %v", tt.changed, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package launch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+var (
+	defaultIdentitySource = "method.request.header.Authorization"
+	defaultAuthorizerTTL  = int64(300)
+)
+
+// access describes how callers are authorized on a method.
+type access struct {
+	authorizationType string
+	authorizerID      *string
+	apiKeyRequired    bool
+}
+
+// methodAccess returns the access settings for the resource at path. Public paths skip
+// both the authorizer and the API key requirement.
+func methodAccess(path string, authorizer *ag.Authorizer, conf *Config) access {
+	if isPublicPath(path, conf) {
+		return access{authorizationType: "NONE"}
+	}
+
+	acc := access{
+		authorizationType: authorizationType(conf),
+		apiKeyRequired:    conf.APIKeys.Required,
+	}
+	if authorizer != nil {
+		acc.authorizerID = authorizer.Id
+	}
+
+	return acc
+}
+
+func isPublicPath(path string, conf *Config) bool {
+	for _, public := range conf.Auth.Public {
+		if "/"+strings.Trim(public, "/") == path {
+			return true
+		}
+	}
+	return false
+}
+
+func authorizationType(conf *Config) string {
+	switch conf.Auth.Type {
+	case "cognito":
+		return "COGNITO_USER_POOLS"
+	case "token", "request":
+		return "CUSTOM"
+	case "iam":
+		return "AWS_IAM"
+	}
+	return "NONE"
+}
+
+// authorizerType returns the API Gateway authorizer type, or an empty string when the
+// configured auth type doesn't use an authorizer.
+func authorizerType(conf *Config) string {
+	switch conf.Auth.Type {
+	case "cognito":
+		return ag.AuthorizerTypeCognitoUserPools
+	case "token":
+		return ag.AuthorizerTypeToken
+	case "request":
+		return ag.AuthorizerTypeRequest
+	}
+	return ""
+}
+
+func getOrCreateAuthorizer(client *ag.APIGateway, api *ag.RestApi, fn *lambda.FunctionConfiguration, conf *Config) (*ag.Authorizer, error) {
+	if authorizerType(conf) == "" {
+		return nil, nil
+	}
+
+	authorizer, err := getAuthorizer(client, api, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if authorizer == nil {
+		created(conf, "authorizer", authorizerName(conf), conf.Auth.Type)
+		authorizer, err = createAuthorizer(client, api, conf)
+	} else if patches := authorizerPatches(authorizer, conf); len(patches) > 0 {
+		updated(conf, "authorizer", authorizerName(conf), "")
+		authorizer, err = client.UpdateAuthorizer(&ag.UpdateAuthorizerInput{
+			RestApiId:       api.Id,
+			AuthorizerId:    authorizer.Id,
+			PatchOperations: patches,
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Auth.Function != "" {
+		if err = addAuthorizerPermission(api, authorizer, fn, conf); err != nil {
+			return nil, fmt.Errorf("unable to let API Gateway invoke the authorizer function: %w", err)
+		}
+	}
+
+	return authorizer, nil
+}
+
+func getAuthorizer(client *ag.APIGateway, api *ag.RestApi, conf *Config) (*ag.Authorizer, error) {
+	authorizers, err := client.GetAuthorizers(&ag.GetAuthorizersInput{
+		RestApiId: api.Id,
+		Limit:     aws.Int64(100),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, authorizer := range authorizers.Items {
+		if *authorizer.Name == authorizerName(conf) {
+			return authorizer, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func createAuthorizer(client *ag.APIGateway, api *ag.RestApi, conf *Config) (*ag.Authorizer, error) {
+	input := &ag.CreateAuthorizerInput{
+		RestApiId:                    api.Id,
+		Name:                         aws.String(authorizerName(conf)),
+		Type:                         aws.String(authorizerType(conf)),
+		IdentitySource:               aws.String(identitySource(conf)),
+		AuthorizerResultTtlInSeconds: aws.Int64(authorizerTTL(conf)),
+	}
+
+	if conf.Auth.Function != "" {
+		input.AuthorizerUri = aws.String(authorizerURI(conf))
+	}
+
+	if len(conf.Auth.UserPools) > 0 {
+		input.ProviderARNs = aws.StringSlice(conf.Auth.UserPools)
+	}
+
+	return client.CreateAuthorizer(input)
+}
+
+func authorizerPatches(authorizer *ag.Authorizer, conf *Config) []*ag.PatchOperation {
+	var patches []*ag.PatchOperation
+	replace := func(path, value string) {
+		patches = append(patches, &ag.PatchOperation{
+			Op:    aws.String(ag.OpReplace),
+			Path:  aws.String(path),
+			Value: aws.String(value),
+		})
+	}
+
+	if aws.StringValue(authorizer.Type) != authorizerType(conf) {
+		replace("/type", authorizerType(conf))
+	}
+
+	if aws.StringValue(authorizer.IdentitySource) != identitySource(conf) {
+		replace("/identitySource", identitySource(conf))
+	}
+
+	if aws.Int64Value(authorizer.AuthorizerResultTtlInSeconds) != authorizerTTL(conf) {
+		replace("/authorizerResultTtlInSeconds", strconv.FormatInt(authorizerTTL(conf), 10))
+	}
+
+	if conf.Auth.Function != "" && aws.StringValue(authorizer.AuthorizerUri) != authorizerURI(conf) {
+		replace("/authorizerUri", authorizerURI(conf))
+	}
+
+	current := aws.StringValueSlice(authorizer.ProviderARNs)
+	for _, arn := range conf.Auth.UserPools {
+		if !contains(current, arn) {
+			patches = append(patches, &ag.PatchOperation{Op: aws.String(ag.OpAdd), Path: aws.String("/providerARNs"), Value: aws.String(arn)})
+		}
+	}
+	for _, arn := range current {
+		if !contains(conf.Auth.UserPools, arn) {
+			patches = append(patches, &ag.PatchOperation{Op: aws.String(ag.OpRemove), Path: aws.String("/providerARNs"), Value: aws.String(arn)})
+		}
+	}
+
+	return patches
+}
+
+// addAuthorizerPermission allows API Gateway to call the Lambda authorizer of this API.
+func addAuthorizerPermission(api *ag.RestApi, authorizer *ag.Authorizer, fn *lambda.FunctionConfiguration, conf *Config) error {
+	client := lambda.New(conf.Session)
+
+	client.RemovePermission(&lambda.RemovePermissionInput{
+		FunctionName: aws.String(conf.Auth.Function),
+		StatementId:  aws.String(authorizerName(conf)),
+	})
+
+	_, err := client.AddPermission(&lambda.AddPermissionInput{
+		FunctionName: aws.String(conf.Auth.Function),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("apigateway.amazonaws.com"),
+		StatementId:  aws.String(authorizerName(conf)),
+		SourceArn: aws.String(fmt.Sprintf(
+			"arn:aws:execute-api:%v:%v:%v/authorizers/%v",
+			conf.Region,
+			accountID(*fn.FunctionArn),
+			*api.Id,
+			*authorizer.Id,
+		)),
+	})
+	return err
+}
+
+func identitySource(conf *Config) string {
+	if conf.Auth.IdentitySource != "" {
+		return conf.Auth.IdentitySource
+	}
+	return defaultIdentitySource
+}
+
+func authorizerTTL(conf *Config) int64 {
+	if conf.Auth.TTL != 0 {
+		return conf.Auth.TTL
+	}
+	return defaultAuthorizerTTL
+}
+
+func authorizerURI(conf *Config) string {
+	return fmt.Sprintf(
+		"arn:aws:apigateway:%v:lambda:path/2015-03-31/functions/%v/invocations",
+		conf.Region,
+		conf.Auth.Function,
+	)
+}
+
+func authorizerName(conf *Config) string {
+	return conf.Name + "-authorizer"
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// ErrorMetrics reports how many invocations of a function version failed since a point
+// in time. CloudWatchMetrics reads them from CloudWatch, other implementations can stand
+// in for it where CloudWatch isn't available.
+type ErrorMetrics interface {
+	Errors(conf *Config, version string, since time.Time) (float64, error)
+}
+
+type CloudWatchMetrics struct{}
+
+// Errors sums the Errors metric of the version, as invoked through the environment alias.
+func (CloudWatchMetrics) Errors(conf *Config, version string, since time.Time) (float64, error) {
+	client := cloudwatch.New(conf.Session)
+
+	stats, err := client.GetMetricStatistics(&cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/Lambda"),
+		MetricName: aws.String("Errors"),
+		Dimensions: []*cloudwatch.Dimension{
+			{Name: aws.String("FunctionName"), Value: aws.String(conf.Name)},
+			{Name: aws.String("Resource"), Value: aws.String(fmt.Sprintf("%v:%v", conf.Name, conf.Environment))},
+			{Name: aws.String("ExecutedVersion"), Value: aws.String(version)},
+		},
+		StartTime:  aws.Time(since),
+		EndTime:    aws.Time(time.Now()),
+		Period:     aws.Int64(60),
+		Statistics: aws.StringSlice([]string{cloudwatch.StatisticSum}),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var sum float64
+	for _, point := range stats.Datapoints {
+		sum += aws.Float64Value(point.Sum)
+	}
+
+	return sum, nil
+}
+
+// RunCanary moves the canary of the current environment through the configured steps.
+// Before each step it waits for the configured interval and checks the errors of the
+// canary version. The canary is aborted if there are more than MaxErrors of them, and
+// promoted once a step reaches 100%. When ctx is cancelled while waiting, the canary is
+// aborted as well.
+func RunCanary(ctx context.Context, conf *Config, metrics ErrorMetrics) error {
+	client := lambda.New(conf.Session)
+
+	alias, err := getAlias(client, conf)
+	if err != nil {
+		return err
+	}
+
+	stable, canary, _ := aliasRouting(alias)
+	if canary == "" {
+		return nil
+	}
+
+	interval, err := time.ParseDuration(conf.Canary.Interval)
+	if err != nil {
+		return fmt.Errorf("invalid canary interval '%v': %w", conf.Canary.Interval, err)
+	}
+
+	for _, step := range conf.Canary.Steps {
+		if step <= conf.Canary.Weight {
+			continue
+		}
+
+		since := time.Now()
+		info(conf, "Waiting %v before routing %v%% of '%v' to version %v", interval, step, conf.Environment, canary)
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			warn(conf, "cancelled while routing part of '%v' to version %v, aborting", conf.Environment, canary)
+			if _, err = AbortCanary(withoutContext(conf)); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+
+		errors, err := metrics.Errors(conf, canary, since)
+		if err != nil {
+			return fmt.Errorf("unable to read error metrics of version %v: %w", canary, err)
+		}
+
+		if errors > conf.Canary.MaxErrors {
+			warn(conf, "version %v had %v errors in the last %v, aborting", canary, errors, interval)
+			if _, err = AbortCanary(conf); err != nil {
+				return err
+			}
+			return &CanaryAbortedError{Version: canary, Errors: errors}
+		}
+
+		if step >= 100 {
+			_, err = PromoteCanary(conf)
+			return err
+		}
+
+		info(conf, "Routing %v%% of '%v' to version %v", step, conf.Environment, canary)
+		if err = routeAlias(client, stable, canary, step, conf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withoutContext returns a copy of conf whose calls are no longer cancelled along with
+// their context, so that the canary can still be rolled back. The values of the context,
+// like the retry budget, are kept.
+func withoutContext(conf *Config) *Config {
+	c := *conf
+	c.Session = conf.Session.Copy()
+	c.Session.Handlers.Build.PushBack(func(r *request.Request) {
+		r.SetContext(context.WithoutCancel(r.Context()))
+	})
+	return &c
+}
+
+// PromoteCanary points the alias of the current environment at the canary version and
+// removes the routing. It returns the promoted version.
+func PromoteCanary(conf *Config) (string, error) {
+	client := lambda.New(conf.Session)
+
+	alias, err := getAlias(client, conf)
+	if err != nil {
+		return "", err
+	}
+
+	_, canary, _ := aliasRouting(alias)
+	if canary == "" {
+		return "", fmt.Errorf("there is no canary in '%v'", conf.Environment)
+	}
+
+	info(conf, "Routing all of '%v' to version %v", conf.Environment, canary)
+	return canary, updateAlias(client, &lambda.FunctionConfiguration{Version: aws.String(canary)}, conf)
+}
+
+// AbortCanary removes the routing from the alias of the current environment, sending all
+// traffic back to the stable version. It returns the dropped version.
+func AbortCanary(conf *Config) (string, error) {
+	client := lambda.New(conf.Session)
+
+	alias, err := getAlias(client, conf)
+	if err != nil {
+		return "", err
+	}
+
+	stable, canary, _ := aliasRouting(alias)
+	if canary == "" {
+		return "", fmt.Errorf("there is no canary in '%v'", conf.Environment)
+	}
+
+	info(conf, "Routing all of '%v' back to version %v", conf.Environment, stable)
+	return canary, updateAlias(client, &lambda.FunctionConfiguration{Version: aws.String(stable)}, conf)
+}
+
+// aliasRouting returns the stable version of an alias, and the canary version and its
+// weight when part of the traffic is routed to another version.
+func aliasRouting(alias *lambda.AliasConfiguration) (string, string, float64) {
+	if alias == nil {
+		return "", "", 0
+	}
+
+	if alias.RoutingConfig != nil {
+		for version, weight := range alias.RoutingConfig.AdditionalVersionWeights {
+			return *alias.FunctionVersion, version, aws.Float64Value(weight)
+		}
+	}
+
+	return *alias.FunctionVersion, "", 0
+}
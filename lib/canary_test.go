@@ -0,0 +1,155 @@
+package launch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// fakeMetrics reports the same number of errors for every check.
+type fakeMetrics struct {
+	errors float64
+	checks int
+}
+
+func (m *fakeMetrics) Errors(conf *Config, version string, since time.Time) (float64, error) {
+	m.checks++
+	return m.errors, nil
+}
+
+// fakeAlias serves GetAlias and UpdateAlias for a single alias, and records the weight
+// given to the canary by each update.
+type fakeAlias struct {
+	mu      sync.Mutex
+	version string
+	weights map[string]float64
+	updates []map[string]float64
+}
+
+func (a *fakeAlias) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if r.Method == http.MethodPut {
+		var body struct {
+			FunctionVersion string
+			RoutingConfig   struct{ AdditionalVersionWeights map[string]float64 }
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.version = body.FunctionVersion
+		a.weights = body.RoutingConfig.AdditionalVersionWeights
+		a.updates = append(a.updates, a.weights)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"Name":            "dev",
+		"FunctionVersion": a.version,
+		"RoutingConfig":   map[string]interface{}{"AdditionalVersionWeights": a.weights},
+	})
+}
+
+func canaryConfig(t *testing.T, alias *fakeAlias) *Config {
+	server := httptest.NewServer(alias)
+	t.Cleanup(server.Close)
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+
+	return &Config{
+		Name:        "app",
+		Environment: "dev",
+		Session:     sess,
+		Canary: Canary{
+			Weight:   10,
+			Steps:    []int{10, 50, 100},
+			Interval: "1ms",
+		},
+	}
+}
+
+func TestRunCanaryPromotes(t *testing.T) {
+	alias := &fakeAlias{version: "1", weights: map[string]float64{"2": 0.1}}
+	conf := canaryConfig(t, alias)
+	metrics := &fakeMetrics{}
+
+	if err := RunCanary(context.Background(), conf, metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	if alias.version != "2" || len(alias.weights) != 0 {
+		t.Errorf("expected all traffic on version 2, got %v with %v", alias.version, alias.weights)
+	}
+	if len(alias.updates) != 2 || alias.updates[0]["2"] != 0.5 {
+		t.Errorf("expected a step to 50%% before promoting, got %v", alias.updates)
+	}
+	if metrics.checks != 2 {
+		t.Errorf("expected the errors to be checked before each step, got %v checks", metrics.checks)
+	}
+}
+
+func TestRunCanaryAborts(t *testing.T) {
+	alias := &fakeAlias{version: "1", weights: map[string]float64{"2": 0.1}}
+	conf := canaryConfig(t, alias)
+	conf.Canary.MaxErrors = 1
+
+	err := RunCanary(context.Background(), conf, &fakeMetrics{errors: 2})
+
+	var aborted *CanaryAbortedError
+	if !errors.As(err, &aborted) || aborted.Version != "2" {
+		t.Fatalf("expected the canary of version 2 to be aborted, got %v", err)
+	}
+	if alias.version != "1" || len(alias.weights) != 0 {
+		t.Errorf("expected all traffic back on version 1, got %v with %v", alias.version, alias.weights)
+	}
+}
+
+func TestRunCanaryAllowsErrorsUpToThreshold(t *testing.T) {
+	alias := &fakeAlias{version: "1", weights: map[string]float64{"2": 0.1}}
+	conf := canaryConfig(t, alias)
+	conf.Canary.MaxErrors = 2
+
+	if err := RunCanary(context.Background(), conf, &fakeMetrics{errors: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if alias.version != "2" {
+		t.Errorf("expected version 2 to be promoted, got %v", alias.version)
+	}
+}
+
+func TestRunCanaryAbortsWhenCancelled(t *testing.T) {
+	alias := &fakeAlias{version: "1", weights: map[string]float64{"2": 0.1}}
+	conf := canaryConfig(t, alias)
+	conf.Canary.Interval = "1h"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conf.Session.Handlers.Build.PushFront(func(r *request.Request) {
+		r.SetContext(ctx)
+	})
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	if err := RunCanary(ctx, conf, &fakeMetrics{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the canary to stop when cancelled, got %v", err)
+	}
+
+	if alias.version != "1" || len(alias.weights) != 0 {
+		t.Errorf("expected all traffic back on version 1, got %v with %v", alias.version, alias.weights)
+	}
+}
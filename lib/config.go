@@ -4,22 +4,245 @@ import (
 	"os"
 
 	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"gopkg.in/yaml.v2"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
 	Session     *session.Session `yaml:"-"`
+	Listener    Listener         `yaml:"-"`
 	Name        string
 	Description string
 	Region      string
+	Account     string `yaml:"account,omitempty"`
+	Profile     string `yaml:"profile,omitempty"`
+	AssumeRole  string `yaml:"assume-role,omitempty" mapstructure:"assume-role"`
+	ExternalID  string `yaml:"external-id,omitempty" mapstructure:"external-id"`
+	MFASerial   string `yaml:"mfa-serial,omitempty" mapstructure:"mfa-serial"`
+	Retry       Retry  `yaml:"-"`
 	Environment string `yaml:"default-environment"`
 	Port        int
 	Variables   map[string]map[string]string
+	APIKeys     APIKeys                      `yaml:"api-keys,omitempty" mapstructure:"api-keys"`
+	Auth        Auth                         `yaml:"auth,omitempty"`
+	CORS        CORS                         `yaml:"cors,omitempty"`
+	Stage       map[string]StageSettings     `yaml:"stage,omitempty"`
+	Canary      Canary                       `yaml:"canary,omitempty"`
+	AccessLog   AccessLog                    `yaml:"access-log,omitempty" mapstructure:"access-log"`
+	Source      string                       `yaml:"source,omitempty"`
+	Server      string                       `yaml:"server,omitempty"`
+	Routes      []Route                      `yaml:"routes,omitempty"`
+	Static      Static                       `yaml:"static,omitempty"`
+	Schedules   []Schedule                   `yaml:"schedules,omitempty"`
+	Events      []Event                      `yaml:"events,omitempty"`
+	Async       Async                        `yaml:"async,omitempty"`
+	VPC         VPC                          `yaml:"vpc,omitempty"`
+	IAM         IAM                          `yaml:"iam,omitempty"`
+	Roles       Roles                        `yaml:"roles,omitempty"`
+	Hooks       Hooks                        `yaml:"hooks,omitempty"`
+	SmokeTests  []SmokeTest                  `yaml:"smoke-tests,omitempty" mapstructure:"smoke-tests"`
+	Envs        map[string]Environment       `yaml:"environments,omitempty" mapstructure:"environments"`
+	Tags        map[string]string            `yaml:"tags,omitempty"`
+	EnvTags     map[string]map[string]string `yaml:"environment-tags,omitempty" mapstructure:"environment-tags"`
+
+	// ctx is the context of the Deploy, Plan or Destroy the config is used by.
+	ctx context.Context
+}
+
+// runContext returns the context of the Deploy, Plan or Destroy the config is used by, for
+// work that isn't an AWS call.
+func (c *Config) runContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Route sends a path prefix, and everything below it, to a function of its own. The
+// function is packaged from Source, which defaults to the route name. Async replaces the
+// async settings of the app for the route.
+type Route struct {
+	Path        string
+	Name        string
+	Source      string
+	Server      string
+	Port        int
+	Description string
+	Async       *Async
+}
+
+// APIKeys makes the API require a key on every request. Keys are attached to the
+// usage plan of the environment they are created for, which throttles them.
+type APIKeys struct {
+	Required bool
+	Plans    map[string]UsagePlan `yaml:"usage-plans,omitempty" mapstructure:"usage-plans"`
+}
+
+// Auth puts an authorizer in front of the app. Type is one of 'cognito', 'token',
+// 'request' or 'iam'. Paths listed in Public are left unauthenticated.
+type Auth struct {
+	Type           string
+	UserPools      []string `yaml:"user-pools,omitempty" mapstructure:"user-pools"`
+	Function       string
+	IdentitySource string `yaml:"identity-source,omitempty" mapstructure:"identity-source"`
+	TTL            int64
+	Public         []string
+}
+
+// CORS answers preflight requests in API Gateway and adds CORS headers to the errors it
+// returns. Inject makes the shim add the headers to responses from the app as well.
+type CORS struct {
+	Origins     []string
+	Methods     []string
+	Headers     []string
+	Credentials bool
+	MaxAge      int `yaml:"max-age,omitempty" mapstructure:"max-age"`
+	Inject      bool
+}
+
+// StageSettings are applied to the API Gateway stage of an environment after every
+// deployment. Logging is one of OFF, ERROR or INFO.
+type StageSettings struct {
+	Throttling Throttling
+	Cache      Cache
+	Logging    string
+	DataTrace  bool `yaml:"data-trace,omitempty" mapstructure:"data-trace"`
+	Metrics    bool
+	Tracing    bool
+}
+
+type Throttling struct {
+	Rate  float64
+	Burst int64
+}
+
+// Cache enables the stage cache cluster. Size is in GB, e.g. "0.5" or "1.6".
+type Cache struct {
+	Size string
+	TTL  int64
+}
+
+// Canary controls releases started with the --canary flag, which sets Weight. Steps are
+// percentages the canary moves through, each after Interval has passed without the new
+// version exceeding MaxErrors.
+type Canary struct {
+	Weight    int `yaml:"-" mapstructure:"-"`
+	Steps     []int
+	Interval  string
+	MaxErrors float64 `yaml:"max-errors,omitempty" mapstructure:"max-errors"`
+}
+
+// AccessLog sends the access logs of each stage to a log group of its own. Format is one
+// of the presets 'clf', 'json' and 'csv', or a custom template. Retention is in days.
+type AccessLog struct {
+	Format    string
+	Retention int64
+}
+
+// Static serves the files in Dir from S3, below Prefix, instead of through the function.
+// Every environment has a bucket of its own, which is synced on each launch.
+type Static struct {
+	Dir          string
+	Prefix       string
+	CacheControl string `yaml:"cache-control,omitempty" mapstructure:"cache-control"`
+}
+
+// Schedule sends a request to the app on a cron or rate expression, e.g. 'cron(0 3 * * ? *)'
+// or 'rate(1 hour)'. Method defaults to GET, and Body is sent as JSON.
+type Schedule struct {
+	Name     string
+	Schedule string
+	Method   string
+	Path     string
+	Body     string
+}
+
+// Event delivers the records of a queue, topic, bucket or event bus to the app, as POST
+// requests to Path. Type is one of 'sqs', 'sns', 's3' and 'eventbridge'. Source holds the
+// queue ARN, topic ARN, bucket name or event bus name of each environment the event is
+// enabled in.
+type Event struct {
+	Name      string
+	Type      string
+	Path      string
+	Source    map[string]string
+	BatchSize int64    `yaml:"batch-size,omitempty" mapstructure:"batch-size"`
+	S3Events  []string `yaml:"s3-events,omitempty" mapstructure:"s3-events"`
+	Prefix    string
+	Suffix    string
+	Pattern   string
+}
+
+// Async controls what happens to failed asynchronous invocations, like those of the warmer,
+// schedules and events. MaxRetries is 0 to 2, and MaxEventAge is in seconds. The
+// destinations and the DLQ take the ARN of a queue, topic, function or event bus.
+type Async struct {
+	MaxRetries  *int64 `yaml:"max-retries,omitempty" mapstructure:"max-retries"`
+	MaxEventAge int64  `yaml:"max-event-age,omitempty" mapstructure:"max-event-age"`
+	OnFailure   string `yaml:"on-failure,omitempty" mapstructure:"on-failure"`
+	OnSuccess   string `yaml:"on-success,omitempty" mapstructure:"on-success"`
+	DLQ         string `yaml:"dlq,omitempty"`
+}
+
+// VPC places the functions in private subnets. Environments listed under Environments use
+// those settings instead. Internet tells launch that the app needs to reach the internet,
+// which takes a NAT route from the subnets.
+type VPC struct {
+	Subnets        []string
+	SecurityGroups []string       `yaml:"security-groups,omitempty" mapstructure:"security-groups"`
+	Internet       bool           `yaml:"internet,omitempty"`
+	Environments   map[string]VPC `yaml:"environments,omitempty"`
+}
+
+// IAM gives the functions permissions of their own. Statements make up an inline policy
+// of the function role, and ManagedPolicies are attached to it.
+type IAM struct {
+	Statements      []Statement
+	ManagedPolicies []string `yaml:"managed-policies,omitempty" mapstructure:"managed-policies"`
+}
+
+// Statement is a statement of an IAM policy. Effect defaults to Allow.
+type Statement struct {
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+// Roles is for accounts where launch may not create roles. Lambda and API take the ARNs
+// of existing roles to use for the functions and for API Gateway. Boundary, Path and
+// NamePrefix apply to the roles launch does create.
+type Roles struct {
+	Lambda     string
+	API        string
+	CloudWatch string `yaml:"cloudwatch,omitempty" mapstructure:"cloudwatch"`
+	Boundary   string `yaml:"permissions-boundary,omitempty" mapstructure:"permissions-boundary"`
+	Path       string `yaml:"path,omitempty"`
+	NamePrefix string `yaml:"name-prefix,omitempty" mapstructure:"name-prefix"`
+}
+
+// Environment holds the account, region and credentials of an environment, for apps
+// deployed to more than one account. Empty settings fall back to the top level ones.
+type Environment struct {
+	Account    string `yaml:"account,omitempty"`
+	Region     string `yaml:"region,omitempty"`
+	Profile    string `yaml:"profile,omitempty"`
+	AssumeRole string `yaml:"assume-role,omitempty" mapstructure:"assume-role"`
+	ExternalID string `yaml:"external-id,omitempty" mapstructure:"external-id"`
+	MFASerial  string `yaml:"mfa-serial,omitempty" mapstructure:"mfa-serial"`
+}
+
+type UsagePlan struct {
+	Rate   float64
+	Burst  int64
+	Quota  int64
+	Period string
 }
 
 func BootstrapConfig() error {
@@ -93,8 +316,352 @@ func ValidateConfig(conf *Config) []error {
 	if conf.Port == 0 {
 		errs = append(errs, errors.New("'port' is empty"))
 	}
+	if conf.Account != "" && !validAccount(conf.Account) {
+		errs = append(errs, fmt.Errorf("'account' must be a 12 digit account ID, got '%v'", conf.Account))
+	}
+	for name, settings := range conf.Envs {
+		if settings.Account != "" && !validAccount(settings.Account) {
+			errs = append(errs, fmt.Errorf("'account' of environment '%v' must be a 12 digit account ID, got '%v'", name, settings.Account))
+		}
+		if settings.AssumeRole != "" && !strings.HasPrefix(settings.AssumeRole, "arn:") {
+			errs = append(errs, fmt.Errorf("'assume-role' of environment '%v' must be the ARN of a role, got '%v'", name, settings.AssumeRole))
+		}
+	}
+	if conf.AssumeRole != "" && !strings.HasPrefix(conf.AssumeRole, "arn:") {
+		errs = append(errs, fmt.Errorf("'assume-role' must be the ARN of a role, got '%v'", conf.AssumeRole))
+	}
+	if conf.AssumeRole == "" && (conf.ExternalID != "" || conf.MFASerial != "") {
+		errs = append(errs, errors.New("'external-id' and 'mfa-serial' need a role to assume, set 'assume-role'"))
+	}
 	if strings.Contains(conf.Environment, " ") {
 		errs = append(errs, errors.New("'environment' cannot contain spaces"))
 	}
+	switch conf.Auth.Type {
+	case "", "iam":
+	case "cognito":
+		if len(conf.Auth.UserPools) == 0 {
+			errs = append(errs, errors.New("'auth' of type 'cognito' needs at least one of 'user-pools'"))
+		}
+	case "token", "request":
+		if conf.Auth.Function == "" {
+			errs = append(errs, fmt.Errorf("'auth' of type '%v' needs the ARN of a 'function'", conf.Auth.Type))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("'auth' type '%v' is not one of cognito, token, request or iam", conf.Auth.Type))
+	}
+	if conf.CORS.Credentials && contains(conf.CORS.Origins, "*") {
+		errs = append(errs, errors.New("'cors' can't allow credentials for the '*' origin"))
+	}
+	if conf.CORS.Inject && len(conf.CORS.Origins) == 0 {
+		errs = append(errs, errors.New("'cors' needs at least one of 'origins' to inject headers"))
+	}
+	if len(conf.CORS.Origins) > 1 && contains(conf.CORS.Origins, "*") {
+		errs = append(errs, errors.New("'cors' origin '*' can't be combined with other origins"))
+	}
+	for env, stage := range conf.Stage {
+		switch strings.ToUpper(stage.Logging) {
+		case "", "OFF", "ERROR", "INFO":
+		default:
+			errs = append(errs, fmt.Errorf("stage logging for '%v' must be OFF, ERROR or INFO", env))
+		}
+		if stage.Cache.TTL > 0 && stage.Cache.Size == "" {
+			errs = append(errs, fmt.Errorf("stage cache for '%v' needs a 'size'", env))
+		}
+		if !validThrottling(stage.Throttling.Rate, stage.Throttling.Burst) {
+			errs = append(errs, fmt.Errorf("stage throttling for '%v' needs both a 'rate' and a 'burst' above 0", env))
+		}
+	}
+	if conf.Canary.Weight < 0 || conf.Canary.Weight > 99 {
+		errs = append(errs, errors.New("canary weight must be between 1 and 99"))
+	}
+	if len(conf.Canary.Steps) > 0 {
+		if _, err := time.ParseDuration(conf.Canary.Interval); err != nil {
+			errs = append(errs, fmt.Errorf("canary 'interval' is not a duration like '5m': %v", err))
+		}
+		for i, step := range conf.Canary.Steps {
+			if step < 1 || step > 100 || (i > 0 && step <= conf.Canary.Steps[i-1]) {
+				errs = append(errs, errors.New("canary 'steps' must be increasing percentages between 1 and 100"))
+				break
+			}
+		}
+	}
+	if accessLogEnabled(conf) && !strings.Contains(accessLogFormat(conf), "$context.requestId") {
+		errs = append(errs, errors.New("custom 'access-log' formats must include $context.requestId"))
+	}
+	if conf.AccessLog.Retention != 0 && !validRetention(conf.AccessLog.Retention) {
+		errs = append(errs, fmt.Errorf("'access-log' 'retention' must be one of %v days, got %v", joinInts(retentionDays), conf.AccessLog.Retention))
+	}
+	names := map[string]bool{}
+	for _, route := range conf.Routes {
+		if route.Name == "" || strings.ContainsAny(route.Name, " :/") {
+			errs = append(errs, fmt.Errorf("route '%v' needs a 'name' without spaces, colons or slashes", route.Path))
+		}
+		if routePrefix(route) == "/" {
+			errs = append(errs, fmt.Errorf("route '%v' needs a 'path' below /", route.Name))
+		}
+		if route.Port == 0 {
+			errs = append(errs, fmt.Errorf("route '%v' needs a 'port'", route.Name))
+		}
+		if names[route.Name] {
+			errs = append(errs, fmt.Errorf("route name '%v' is used more than once", route.Name))
+		}
+		names[route.Name] = true
+	}
+	if staticEnabled(conf) {
+		if staticPrefix(conf) == "/" {
+			errs = append(errs, errors.New("'static' needs a 'prefix' below /"))
+		}
+		if info, err := os.Stat(conf.Static.Dir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("static 'dir' '%v' is not a directory", conf.Static.Dir))
+		}
+	}
+	schedules := map[string]bool{}
+	for _, schedule := range conf.Schedules {
+		if !validResourceName(schedule.Name) {
+			errs = append(errs, fmt.Errorf("schedule '%v' needs a 'name' of letters, digits, dots, dashes or underscores", schedule.Path))
+		}
+		if !strings.HasPrefix(schedule.Schedule, "cron(") && !strings.HasPrefix(schedule.Schedule, "rate(") {
+			errs = append(errs, fmt.Errorf("schedule '%v' needs a 'schedule' of cron(...) or rate(...)", schedule.Name))
+		}
+		if !strings.HasPrefix(schedule.Path, "/") {
+			errs = append(errs, fmt.Errorf("schedule '%v' needs a 'path' starting with /", schedule.Name))
+		}
+		if schedule.Body != "" && !json.Valid([]byte(schedule.Body)) {
+			errs = append(errs, fmt.Errorf("schedule '%v' needs a 'body' of valid JSON", schedule.Name))
+		}
+		if name := scheduleRuleName(schedule.Name, conf); len(name) > 64 {
+			errs = append(errs, fmt.Errorf("schedule '%v' makes a rule name of %v characters, '%v', and rule names are at most 64 characters long", schedule.Name, len(name), name))
+		}
+		if schedules[schedule.Name] {
+			errs = append(errs, fmt.Errorf("schedule name '%v' is used more than once", schedule.Name))
+		}
+		schedules[schedule.Name] = true
+	}
+	events := map[string]bool{}
+	for _, event := range conf.Events {
+		if !validResourceName(event.Name) {
+			errs = append(errs, fmt.Errorf("event '%v' needs a 'name' of letters, digits, dots, dashes or underscores", event.Path))
+		}
+		if !contains(eventTypes, event.Type) {
+			errs = append(errs, fmt.Errorf("event '%v' needs a 'type' of %v", event.Name, strings.Join(eventTypes, ", ")))
+		}
+		if !strings.HasPrefix(event.Path, "/") {
+			errs = append(errs, fmt.Errorf("event '%v' needs a 'path' starting with /", event.Name))
+		}
+		for env, source := range event.Source {
+			if (event.Type == "sqs" || event.Type == "sns") && !strings.HasPrefix(source, "arn:aws:"+event.Type+":") {
+				errs = append(errs, fmt.Errorf("event '%v' needs an %v ARN as 'source' for '%v'", event.Name, event.Type, env))
+			}
+		}
+		if event.Type == "eventbridge" && !json.Valid([]byte(event.Pattern)) {
+			errs = append(errs, fmt.Errorf("event '%v' needs a 'pattern' of valid JSON", event.Name))
+		}
+		if events[event.Name] {
+			errs = append(errs, fmt.Errorf("event name '%v' is used more than once", event.Name))
+		}
+		events[event.Name] = true
+	}
+	errs = append(errs, validateAsync(conf.Async, "async")...)
+	for _, route := range conf.Routes {
+		if route.Async != nil {
+			errs = append(errs, validateAsync(*route.Async, fmt.Sprintf("async of route '%v'", route.Name))...)
+		}
+	}
+	vpcs := map[string]VPC{"vpc": conf.VPC}
+	for env, settings := range conf.VPC.Environments {
+		vpcs[fmt.Sprintf("vpc of '%v'", env)] = settings
+	}
+	for section, settings := range vpcs {
+		if (len(settings.Subnets) == 0) != (len(settings.SecurityGroups) == 0) {
+			errs = append(errs, fmt.Errorf("%v needs both 'subnets' and 'security-groups'", section))
+		}
+	}
+	for i, statement := range conf.IAM.Statements {
+		if len(statement.Action) == 0 || len(statement.Resource) == 0 {
+			errs = append(errs, fmt.Errorf("iam statement %v needs an 'action' and a 'resource'", i+1))
+		}
+		if statement.Effect != "" && statement.Effect != "Allow" && statement.Effect != "Deny" {
+			errs = append(errs, fmt.Errorf("iam statement %v needs an 'effect' of Allow or Deny", i+1))
+		}
+	}
+	for env, vars := range conf.Variables {
+		for name := range vars {
+			if reservedVariable(name) {
+				errs = append(errs, fmt.Errorf("variable '%v' of '%v' is set by launch, use another name", name, env))
+			}
+		}
+	}
+	for hook, commands := range conf.Hooks.byName() {
+		for _, command := range commands {
+			if strings.TrimSpace(command) == "" {
+				errs = append(errs, fmt.Errorf("'hooks.%v' contains an empty command", hook))
+			}
+		}
+	}
+	for _, test := range conf.SmokeTests {
+		if !strings.HasPrefix(test.Path, "/") {
+			errs = append(errs, fmt.Errorf("smoke test '%v' needs a 'path' starting with /", smokeTestName(test)))
+		}
+		if test.Status != 0 && (test.Status < 100 || test.Status > 599) {
+			errs = append(errs, fmt.Errorf("smoke test '%v' needs a 'status' between 100 and 599", smokeTestName(test)))
+		}
+		if _, err := time.ParseDuration(test.MaxLatency); test.MaxLatency != "" && err != nil {
+			errs = append(errs, fmt.Errorf("smoke test '%v' needs a 'max-latency' like 500ms", smokeTestName(test)))
+		}
+		for _, check := range test.JSON {
+			if check.Path == "" {
+				errs = append(errs, fmt.Errorf("smoke test '%v' has a 'json' check without a 'path'", smokeTestName(test)))
+			}
+		}
+	}
+	for _, arn := range conf.IAM.ManagedPolicies {
+		if !strings.HasPrefix(arn, "arn:aws:iam::") {
+			errs = append(errs, fmt.Errorf("managed policy '%v' must be a policy ARN", arn))
+		}
+		if len(arn) > 256 {
+			errs = append(errs, fmt.Errorf("managed policy '%v' must be at most 256 characters long", arn))
+		}
+	}
+	for section, arn := range map[string]string{"lambda": conf.Roles.Lambda, "api": conf.Roles.API, "cloudwatch": conf.Roles.CloudWatch} {
+		if arn != "" && !strings.HasPrefix(arn, "arn:aws:iam::") {
+			errs = append(errs, fmt.Errorf("'roles.%v' must be a role ARN", section))
+		}
+	}
+	if conf.Roles.Boundary != "" && !strings.HasPrefix(conf.Roles.Boundary, "arn:aws:iam::") {
+		errs = append(errs, errors.New("'roles.permissions-boundary' must be a policy ARN"))
+	}
+	if conf.Roles.Path != "" && (!strings.HasPrefix(conf.Roles.Path, "/") || !strings.HasSuffix(conf.Roles.Path, "/")) {
+		errs = append(errs, errors.New("'roles.path' must start and end with /"))
+	}
+	if conf.Roles.Lambda != "" && (len(conf.IAM.Statements) > 0 || len(conf.IAM.ManagedPolicies) > 0) {
+		errs = append(errs, errors.New("'iam' can't be used with 'roles.lambda', the policies of existing roles are left alone"))
+	}
+	tagSets := map[string]map[string]string{"tags": conf.Tags}
+	for env, tags := range conf.EnvTags {
+		tagSets[fmt.Sprintf("environment-tags of '%v'", env)] = tags
+	}
+	for section, tags := range tagSets {
+		for k := range tags {
+			if strings.HasPrefix(k, "launch:") || strings.HasPrefix(k, "aws:") {
+				errs = append(errs, fmt.Errorf("%v can't use '%v', the launch: and aws: prefixes are reserved", section, k))
+			}
+			if strings.ContainsAny(k, " \t\n") {
+				errs = append(errs, fmt.Errorf("%v can't use '%v', tag keys can't contain whitespace", section, k))
+			}
+		}
+	}
+	for env := range conf.EnvTags {
+		merged := copyTags(conf.Tags)
+		for k, v := range conf.EnvTags[env] {
+			merged[k] = v
+		}
+		if len(managedTags(merged)) > 256 {
+			errs = append(errs, fmt.Errorf("the tag keys of '%v' must fit in 256 characters once joined by spaces", env))
+		}
+	}
+	if len(managedTags(conf.Tags)) > 256 {
+		errs = append(errs, errors.New("the keys of 'tags' must fit in 256 characters once joined by spaces"))
+	}
+	for env, plan := range conf.APIKeys.Plans {
+		if plan.Quota > 0 && !validQuotaPeriod(plan.Period) {
+			errs = append(errs, fmt.Errorf("usage plan for '%v' needs a 'period' of DAY, WEEK or MONTH", env))
+		}
+		if !validThrottling(plan.Rate, plan.Burst) {
+			errs = append(errs, fmt.Errorf("usage plan for '%v' needs both a 'rate' and a 'burst' above 0", env))
+		}
+	}
+	return errs
+}
+
+// validThrottling requires both limits or neither, since a missing burst limit of 0
+// rejects every request.
+func validThrottling(rate float64, burst int64) bool {
+	if rate == 0 && burst == 0 {
+		return true
+	}
+	return rate > 0 && burst > 0
+}
+
+// retentionDays are the retention periods CloudWatch Logs accepts.
+var retentionDays = []int64{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
+func validRetention(days int64) bool {
+	for _, d := range retentionDays {
+		if d == days {
+			return true
+		}
+	}
+	return false
+}
+
+func joinInts(values []int64) string {
+	var s []string
+	for _, v := range values {
+		s = append(s, strconv.FormatInt(v, 10))
+	}
+	return strings.Join(s, ", ")
+}
+
+func validQuotaPeriod(period string) bool {
+	switch strings.ToUpper(period) {
+	case "DAY", "WEEK", "MONTH":
+		return true
+	}
+	return false
+}
+
+func validateAsync(async Async, section string) []error {
+	var errs []error
+	if async.MaxRetries != nil && (*async.MaxRetries < 0 || *async.MaxRetries > 2) {
+		errs = append(errs, fmt.Errorf("%v 'max-retries' must be between 0 and 2", section))
+	}
+	if async.MaxEventAge != 0 && (async.MaxEventAge < 60 || async.MaxEventAge > 21600) {
+		errs = append(errs, fmt.Errorf("%v 'max-event-age' must be between 60 and 21600 seconds", section))
+	}
+	for _, arn := range []string{async.OnFailure, async.OnSuccess} {
+		if _, known := destinationActions[arnService(arn)]; arn != "" && !known {
+			errs = append(errs, fmt.Errorf("%v destination '%v' must be a queue, topic, function or event bus ARN", section, arn))
+		}
+	}
+	if s := arnService(async.DLQ); async.DLQ != "" && s != "sqs" && s != "sns" {
+		errs = append(errs, fmt.Errorf("%v 'dlq' must be a queue or topic ARN", section))
+	}
 	return errs
 }
+
+// validResourceName checks that a schedule or event name can be part of a rule name.
+func validResourceName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.-_", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// sourceDir returns the directory the function is packaged from.
+func sourceDir(conf *Config) string {
+	if conf.Source != "" {
+		return conf.Source
+	}
+	return "."
+}
+
+// serverFile returns the name of the file that starts the app, relative to its source.
+func serverFile(conf *Config) string {
+	if conf.Server != "" {
+		return conf.Server
+	}
+	return "server"
+}
+
+func validAccount(account string) bool {
+	if len(account) != 12 {
+		return false
+	}
+	_, err := strconv.ParseUint(account, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,247 @@
+package launch
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+var (
+	defaultCORSMethods = []string{"OPTIONS", "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", "X-Api-Key"}
+
+	corsGatewayResponses = []string{ag.GatewayResponseTypeDefault4xx, ag.GatewayResponseTypeDefault5xx}
+)
+
+func corsEnabled(conf *Config) bool {
+	return len(conf.CORS.Origins) > 0
+}
+
+// getOrCreateCORS answers preflight requests on the resource with a MOCK integration, so
+// they never reach the function. The OPTIONS method is removed again when CORS is disabled.
+func getOrCreateCORS(client *ag.APIGateway, api *ag.RestApi, resource *ag.Resource, conf *Config) error {
+	method, err := getMethod(client, api, resource, "OPTIONS")
+	if err != nil {
+		return err
+	}
+
+	if method != nil && !isCORSMethod(method) {
+		warn(conf, "leaving the 'OPTIONS' method on '%v' alone, it isn't managed by launch", *resource.Path)
+		return nil
+	}
+
+	if method != nil {
+		if corsEnabled(conf) && corsUpToDate(method, conf) {
+			return nil
+		}
+
+		deleted(conf, "method", "OPTIONS "+*resource.Path)
+		_, err = client.DeleteMethod(&ag.DeleteMethodInput{
+			RestApiId:  api.Id,
+			ResourceId: resource.Id,
+			HttpMethod: aws.String("OPTIONS"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !corsEnabled(conf) {
+		return nil
+	}
+
+	created(conf, "method", "OPTIONS "+*resource.Path, "")
+	return createCORSMethod(client, api, resource, conf)
+}
+
+func createCORSMethod(client *ag.APIGateway, api *ag.RestApi, resource *ag.Resource, conf *Config) error {
+	_, err := client.PutMethod(&ag.PutMethodInput{
+		RestApiId:         api.Id,
+		ResourceId:        resource.Id,
+		HttpMethod:        aws.String("OPTIONS"),
+		AuthorizationType: aws.String("NONE"),
+	})
+	if err != nil {
+		return err
+	}
+
+	params := map[string]*bool{}
+	for header := range corsHeaders(conf) {
+		params["method.response.header."+header] = aws.Bool(false)
+	}
+
+	_, err = client.PutMethodResponse(&ag.PutMethodResponseInput{
+		RestApiId:          api.Id,
+		ResourceId:         resource.Id,
+		HttpMethod:         aws.String("OPTIONS"),
+		StatusCode:         aws.String("200"),
+		ResponseParameters: params,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.PutIntegration(&ag.PutIntegrationInput{
+		RestApiId:  api.Id,
+		ResourceId: resource.Id,
+		HttpMethod: aws.String("OPTIONS"),
+		Type:       aws.String(ag.IntegrationTypeMock),
+		RequestTemplates: map[string]*string{
+			"application/json": aws.String(`{"statusCode": 200}`),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.PutIntegrationResponse(&ag.PutIntegrationResponseInput{
+		RestApiId:          api.Id,
+		ResourceId:         resource.Id,
+		HttpMethod:         aws.String("OPTIONS"),
+		StatusCode:         aws.String("200"),
+		ResponseParameters: corsIntegrationParameters(conf),
+		ResponseTemplates:  corsResponseTemplates(conf),
+	})
+	return err
+}
+
+// createOrUpdateCORSResponses adds CORS headers to the errors API Gateway returns by
+// itself, such as failed authorization or throttling, so browsers can read them.
+func createOrUpdateCORSResponses(client *ag.APIGateway, api *ag.RestApi, conf *Config) error {
+	for _, responseType := range corsGatewayResponses {
+		response, err := client.GetGatewayResponse(&ag.GetGatewayResponseInput{
+			RestApiId:    api.Id,
+			ResponseType: aws.String(responseType),
+		})
+		if err != nil {
+			return err
+		}
+
+		managed := response.ResponseParameters["gatewayresponse.header.Access-Control-Allow-Origin"] != nil
+
+		if !corsEnabled(conf) {
+			if managed && !aws.BoolValue(response.DefaultResponse) {
+				updated(conf, "gateway response", responseType, "removed CORS headers")
+				_, err = client.DeleteGatewayResponse(&ag.DeleteGatewayResponseInput{
+					RestApiId:    api.Id,
+					ResponseType: aws.String(responseType),
+				})
+			}
+		} else if !reflect.DeepEqual(aws.StringValueMap(response.ResponseParameters), aws.StringValueMap(corsGatewayParameters(conf))) {
+			updated(conf, "gateway response", responseType, "CORS headers")
+			_, err = client.PutGatewayResponse(&ag.PutGatewayResponseInput{
+				RestApiId:          api.Id,
+				ResponseType:       aws.String(responseType),
+				ResponseParameters: corsGatewayParameters(conf),
+			})
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isCORSMethod(method *ag.Method) bool {
+	return method.MethodIntegration != nil && aws.StringValue(method.MethodIntegration.Type) == ag.IntegrationTypeMock
+}
+
+func corsUpToDate(method *ag.Method, conf *Config) bool {
+	response, defined := method.MethodIntegration.IntegrationResponses["200"]
+	if !defined {
+		return false
+	}
+
+	return reflect.DeepEqual(aws.StringValueMap(response.ResponseParameters), aws.StringValueMap(corsIntegrationParameters(conf))) &&
+		reflect.DeepEqual(aws.StringValueMap(response.ResponseTemplates), aws.StringValueMap(corsResponseTemplates(conf)))
+}
+
+// corsHeaders returns the headers sent in response to a preflight request.
+func corsHeaders(conf *Config) map[string]string {
+	methods := conf.CORS.Methods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+
+	headers := conf.CORS.Headers
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+
+	values := map[string]string{
+		"Access-Control-Allow-Origin":  conf.CORS.Origins[0],
+		"Access-Control-Allow-Methods": strings.Join(methods, ","),
+		"Access-Control-Allow-Headers": strings.Join(headers, ","),
+	}
+
+	if conf.CORS.Credentials {
+		values["Access-Control-Allow-Credentials"] = "true"
+	}
+
+	if conf.CORS.MaxAge > 0 {
+		values["Access-Control-Max-Age"] = strconv.Itoa(conf.CORS.MaxAge)
+	}
+
+	if len(conf.CORS.Origins) > 1 {
+		values["Vary"] = "Origin"
+	}
+
+	return values
+}
+
+func corsIntegrationParameters(conf *Config) map[string]*string {
+	params := map[string]*string{}
+	for header, value := range corsHeaders(conf) {
+		params["method.response.header."+header] = aws.String(quoteMapping(value))
+	}
+	return params
+}
+
+// corsResponseTemplates echoes the Origin of the request when it is one of several allowed
+// origins, since the Access-Control-Allow-Origin header can only hold a single value.
+func corsResponseTemplates(conf *Config) map[string]*string {
+	if len(conf.CORS.Origins) < 2 {
+		return nil
+	}
+
+	var checks []string
+	for _, origin := range conf.CORS.Origins {
+		checks = append(checks, fmt.Sprintf(`$origin == "%v"`, origin))
+	}
+
+	return map[string]*string{
+		"application/json": aws.String(fmt.Sprintf(`#set($origin = $input.params("Origin"))
+#if($origin == "")#set($origin = $input.params("origin"))#end
+#if(%v)
+#set($context.responseOverride.header.Access-Control-Allow-Origin = $origin)
+#end`, strings.Join(checks, " || "))),
+	}
+}
+
+// corsGatewayParameters returns the headers added to gateway errors. Gateway responses
+// can't check the origin of a request against a list, so with several allowed origins the
+// headers are left out rather than allowing any origin.
+func corsGatewayParameters(conf *Config) map[string]*string {
+	params := map[string]*string{}
+	if len(conf.CORS.Origins) != 1 {
+		return params
+	}
+
+	params["gatewayresponse.header.Access-Control-Allow-Origin"] = aws.String(quoteMapping(conf.CORS.Origins[0]))
+	if conf.CORS.Credentials {
+		params["gatewayresponse.header.Access-Control-Allow-Credentials"] = aws.String(quoteMapping("true"))
+	}
+
+	return params
+}
+
+// quoteMapping turns a value into a static value in a parameter mapping expression.
+func quoteMapping(value string) string {
+	return "'" + value + "'"
+}
@@ -0,0 +1,396 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+	cwe "github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Deployer deploys an app, described by a Config, to one of its environments. Progress is
+// reported to the Listener of the config as notices.
+type Deployer struct {
+	conf *Config
+}
+
+// Option changes the config of a Deployer.
+type Option func(*Config)
+
+// WithListener sends the notices of the deployment to l.
+func WithListener(l Listener) Option {
+	return func(conf *Config) {
+		conf.Listener = l
+	}
+}
+
+// WithSession makes the Deployer use sess, instead of creating one from the credentials
+// settings of the config.
+func WithSession(sess *session.Session) Option {
+	return func(conf *Config) {
+		conf.Session = sess
+	}
+}
+
+// WithEnvironment deploys to env, with the account, region and credentials declared for it.
+func WithEnvironment(env string) Option {
+	return func(conf *Config) {
+		conf.Environment = env
+		ApplyEnvironment(conf)
+	}
+}
+
+// WithCanary routes weight percent of the traffic to the new version.
+func WithCanary(weight int) Option {
+	return func(conf *Config) {
+		conf.Canary.Weight = weight
+	}
+}
+
+// Result describes a finished deployment. Alias is the ARN of the alias of the environment,
+// and Stage the API stage serving it.
+type Result struct {
+	Environment  string
+	Account      string
+	FunctionARN  string
+	Version      string
+	Alias        string
+	APIID        string
+	Stage        string
+	DeploymentID string
+	URL          string
+	Timings      []Timing
+	Warnings     []string
+}
+
+// Timing is the time a step of a deployment took.
+type Timing struct {
+	Step     string
+	Duration time.Duration
+}
+
+// Change is a change Plan expects Deploy to make. Action is one of create, update or delete.
+type Change struct {
+	Action string
+	Type   string
+	Name   string
+}
+
+// NewDeployer validates a copy of conf with the options applied, and creates a session for
+// it unless one is given.
+func NewDeployer(conf *Config, opts ...Option) (*Deployer, error) {
+	c := *conf
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if errs := ValidateConfig(&c); errs != nil {
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		return nil, &ValidationError{Err: fmt.Errorf("invalid config: %v", strings.Join(messages, "; "))}
+	}
+
+	if c.Session == nil {
+		sess, err := NewSession(&c)
+		if err != nil {
+			return nil, err
+		}
+		c.Session = sess
+	}
+
+	return &Deployer{conf: &c}, nil
+}
+
+type step struct {
+	name string
+	run  func() error
+}
+
+// Deploy creates or updates every resource of the app, and returns where it was deployed.
+// Cancelling ctx stops the deployment at the next AWS call.
+func (d *Deployer) Deploy(ctx context.Context) (*Result, error) {
+	conf := d.withContext(ctx)
+	result := &Result{Environment: conf.Environment}
+	conf.Listener = result.record(conf.Listener)
+
+	var (
+		fn       *lambda.FunctionConfiguration
+		routes   map[string]*lambda.FunctionConfiguration
+		previous map[string]*lambda.AliasConfiguration
+	)
+
+	steps := []step{
+		{"checks", func() error {
+			account, err := checkTarget(conf)
+			result.Account = account
+			if err != nil {
+				return err
+			}
+			if err = CheckServerFile(conf); err != nil {
+				return err
+			}
+			if err = CheckVPC(conf); err != nil {
+				return err
+			}
+			return CheckRoles(conf)
+		}},
+		{"function", func() (err error) {
+			// Kept to roll back to, should the smoke tests fail.
+			if previous, err = currentAliases(functionConfigs(conf)); err != nil {
+				return err
+			}
+			fn, err = CreateOrUpdateFunction(conf)
+			return err
+		}},
+		{"routes", func() (err error) {
+			routes, err = CreateOrUpdateRouteFunctions(conf)
+			return err
+		}},
+		{"static", func() error {
+			return SyncStaticFiles(fn, conf)
+		}},
+		{"api", func() error {
+			return GetOrCreateAPI(fn, routes, conf)
+		}},
+		{"warmers", func() error {
+			if err := CreateOrUpdateFunctionWarmer(fn, conf); err != nil {
+				return err
+			}
+			for _, route := range conf.Routes {
+				if err := CreateOrUpdateFunctionWarmer(routes[route.Name], RouteConfig(route, conf)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"schedules", func() error {
+			return CreateOrUpdateSchedules(fn, routes, conf)
+		}},
+		{"events", func() error {
+			return CreateOrUpdateEventSources(fn, routes, conf)
+		}},
+		{"url", func() error {
+			api, stage, err := getStage(ag.New(conf.Session), conf)
+			if err != nil {
+				return err
+			}
+			result.APIID = *api.Id
+			result.Stage = *stage.StageName
+			result.DeploymentID = aws.StringValue(stage.DeploymentId)
+			result.URL = invokeURL(api, conf)
+			return nil
+		}},
+	}
+
+	if len(conf.SmokeTests) > 0 {
+		steps = append(steps, step{"smoke-tests", func() error {
+			var transport http.RoundTripper
+			if conf.Canary.Weight > 0 {
+				info(conf, "Sending the smoke tests straight to version %v, the canary of '%v'", aws.StringValue(fn.Version), conf.Environment)
+				transport = versionTransport{version: aws.StringValue(fn.Version), conf: conf}
+			}
+			err := runSmokeTests(ctx, result.URL, transport, conf)
+			if err == nil || ctx.Err() != nil {
+				return err
+			}
+			if rollbackErr := rollback(previous, functionConfigs(conf)); rollbackErr != nil {
+				return fmt.Errorf("%w, and the rollback failed: %v", err, rollbackErr)
+			}
+			return err
+		}})
+	}
+
+	if conf.Canary.Weight > 0 && len(conf.Canary.Steps) > 0 {
+		steps = append(steps, step{"canary", func() error {
+			return RunCanary(ctx, conf, CloudWatchMetrics{})
+		}})
+	}
+
+	if len(conf.Hooks.PostDeploy) > 0 {
+		steps = append(steps, step{"post-deploy", func() error {
+			result.setFunction(fn, conf)
+			return runHook("post-deploy", conf.Hooks.PostDeploy, hookEnv(result, conf), conf)
+		}})
+	}
+
+	if err := runSteps(ctx, conf, steps); err != nil {
+		return nil, err
+	}
+
+	result.setFunction(fn, conf)
+	return result, nil
+}
+
+func (r *Result) setFunction(fn *lambda.FunctionConfiguration, conf *Config) {
+	r.FunctionARN = unqualifiedARN(fn)
+	r.Version = aws.StringValue(fn.Version)
+	r.Alias = aliasARN(fn, conf)
+}
+
+// record adds the duration of steps and the warnings to the result, before passing the
+// notices on to next.
+func (r *Result) record(next Listener) Listener {
+	return ListenerFunc(func(n Notice) {
+		switch n := n.(type) {
+		case StepFinished:
+			r.Timings = append(r.Timings, Timing{Step: n.Step, Duration: n.Duration})
+		case Warning:
+			r.Warnings = append(r.Warnings, n.Message)
+		}
+		if next != nil {
+			next.Notify(n)
+		}
+	})
+}
+
+// Plan looks up the functions, roles, API and rules of the app, and returns what Deploy
+// would create, update or delete. Nothing is changed.
+func (d *Deployer) Plan(ctx context.Context) ([]Change, error) {
+	conf := d.withContext(ctx)
+
+	var changes []Change
+	add := func(exists bool, resourceType, name string) {
+		action := "create"
+		if exists {
+			action = "update"
+		}
+		changes = append(changes, Change{Action: action, Type: resourceType, Name: name})
+	}
+
+	lambdaClient := lambda.New(conf.Session)
+	iamClient := iam.New(conf.Session)
+	rules := cwe.New(conf.Session)
+
+	for _, c := range functionConfigs(conf) {
+		exists, err := getFunction(lambdaClient, c)
+		if err != nil {
+			return nil, err
+		}
+		add(exists, "function", c.Name)
+
+		if c.Roles.Lambda == "" {
+			role, err := getRole(iamClient, lambdaRoleName(c))
+			if err != nil {
+				return nil, err
+			}
+			add(role != nil, "role", lambdaRoleName(c))
+		}
+
+		alias, err := getAlias(lambdaClient, c)
+		if err != nil {
+			return nil, err
+		}
+		add(alias != nil, "alias", fmt.Sprintf("%v:%v", c.Name, c.Environment))
+
+		exists, err = ruleExists(rules, ruleName(c))
+		if err != nil {
+			return nil, err
+		}
+		add(exists, "rule", ruleName(c))
+	}
+
+	api, err := getAPI(ag.New(conf.Session), conf)
+	if err != nil {
+		return nil, err
+	}
+	add(api != nil, "API", apiName(conf))
+
+	stage := false
+	if api != nil {
+		_, err = ag.New(conf.Session).GetStage(&ag.GetStageInput{RestApiId: api.Id, StageName: aws.String(conf.Environment)})
+		if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+		stage = err == nil
+	}
+	add(stage, "stage", conf.Environment)
+
+	for _, schedule := range conf.Schedules {
+		exists, err := ruleExists(rules, scheduleRuleName(schedule.Name, conf))
+		if err != nil {
+			return nil, err
+		}
+		add(exists, "schedule", schedule.Name)
+	}
+
+	stale, err := staleSchedules(rules, conf)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range stale {
+		changes = append(changes, Change{Action: "delete", Type: "schedule", Name: strings.TrimPrefix(rule, scheduleRuleName("", conf))})
+	}
+
+	return changes, nil
+}
+
+// withContext returns a copy of the config whose AWS calls are cancelled along with ctx,
+// and share a retry budget that reports to the listener of the copy.
+func (d *Deployer) withContext(ctx context.Context) *Config {
+	c := *d.conf
+	c.ctx = ctx
+	ctx = withRetryBudget(ctx, &c)
+	c.Session = d.conf.Session.Copy()
+	c.Session.Handlers.Build.PushFront(func(r *request.Request) {
+		r.SetContext(ctx)
+	})
+	return &c
+}
+
+// runSteps runs the steps in order, telling the listener when each starts and finishes.
+func runSteps(ctx context.Context, conf *Config, steps []step) error {
+	for _, s := range steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		notify(conf, StepStarted{Step: s.name})
+		start := time.Now()
+		err := s.run()
+		notify(conf, StepFinished{Step: s.name, Duration: time.Since(start), Err: err})
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTarget checks that the credentials belong to the account of the environment, and
+// returns the account.
+func checkTarget(conf *Config) (string, error) {
+	identity, err := CheckAccount(conf)
+	if err != nil {
+		return "", err
+	}
+
+	info(conf, "Deploying '%v' to account %v as %v", conf.Environment, *identity.Account, *identity.Arn)
+	return *identity.Account, nil
+}
+
+// functionConfigs returns the config of the app function, followed by those of the routes.
+func functionConfigs(conf *Config) []*Config {
+	confs := []*Config{conf}
+	for _, route := range conf.Routes {
+		confs = append(confs, RouteConfig(route, conf))
+	}
+	return confs
+}
+
+func ruleExists(client *cwe.CloudWatchEvents, name string) (bool, error) {
+	_, err := client.DescribeRule(&cwe.DescribeRuleInput{Name: aws.String(name)})
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,380 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+	cwe "github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Destroy removes the current environment: its aliases, stage, usage plan, API keys, rules,
+// event subscriptions and static files. Once no environment is left, the functions, the API and
+// the roles launch created are removed as well. Log groups are kept.
+func (d *Deployer) Destroy(ctx context.Context) error {
+	conf := d.withContext(ctx)
+	client := lambda.New(conf.Session)
+
+	// The functions that exist, keyed by name, with the config they were deployed with.
+	fns := map[string]*lambda.FunctionConfiguration{}
+	confs := functionConfigs(conf)
+
+	steps := []step{
+		{"checks", func() error {
+			_, err := checkTarget(conf)
+			if err != nil {
+				return err
+			}
+			for _, c := range confs {
+				fn, err := client.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+					FunctionName: aws.String(c.Name),
+				})
+				if isNotFound(err) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				fns[c.Name] = fn
+			}
+			return nil
+		}},
+		{"schedules", func() error {
+			c := *conf
+			c.Schedules = nil
+			return removeStaleSchedules(cwe.New(conf.Session), &c)
+		}},
+		{"events", func() error {
+			for _, c := range confs {
+				fn, exists := fns[c.Name]
+				if !exists {
+					continue
+				}
+				if err := removeEventSources(fn, c); err != nil {
+					return err
+				}
+				if err := deleteRule(cwe.New(conf.Session), ruleName(c), c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"api", func() error {
+			return deleteStage(ag.New(conf.Session), conf)
+		}},
+		{"static", func() error {
+			fn, exists := fns[conf.Name]
+			if !staticEnabled(conf) || !exists {
+				return nil
+			}
+			return deleteBucket(s3.New(conf.Session), staticBucketName(fn, conf), conf)
+		}},
+		{"aliases", func() error {
+			for _, c := range confs {
+				if _, exists := fns[c.Name]; !exists {
+					continue
+				}
+				_, err := client.DeleteFunctionEventInvokeConfig(&lambda.DeleteFunctionEventInvokeConfigInput{
+					FunctionName: aws.String(c.Name),
+					Qualifier:    aws.String(c.Environment),
+				})
+				if err != nil && !isNotFound(err) {
+					return err
+				}
+
+				statements, err := aliasPermissions(c)
+				if err != nil {
+					return err
+				}
+				for _, statement := range statements {
+					if err = removePermission(statement.Sid, c); err != nil {
+						return err
+					}
+				}
+
+				deleted(c, "alias", fmt.Sprintf("%v:%v", c.Name, c.Environment))
+				_, err = client.DeleteAlias(&lambda.DeleteAliasInput{
+					FunctionName: aws.String(c.Name),
+					Name:         aws.String(c.Environment),
+				})
+				if err != nil && !isNotFound(err) {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"shared", func() error {
+			if _, exists := fns[conf.Name]; !exists {
+				return nil
+			}
+			aliases, err := client.ListAliases(&lambda.ListAliasesInput{FunctionName: aws.String(conf.Name)})
+			if err != nil {
+				return err
+			}
+			if len(aliases.Aliases) > 0 {
+				var envs []string
+				for _, alias := range aliases.Aliases {
+					envs = append(envs, *alias.Name)
+				}
+				info(conf, "Keeping the functions, API and roles, which are still used by %v", strings.Join(envs, ", "))
+				return nil
+			}
+			return deleteShared(fns, confs, conf)
+		}},
+	}
+
+	return runSteps(ctx, conf, steps)
+}
+
+// removeEventSources unsubscribes the alias of the current environment from its queues,
+// topics, buckets and event buses.
+func removeEventSources(fn *lambda.FunctionConfiguration, conf *Config) error {
+	alias := aliasARN(fn, conf)
+
+	if err := removeStaleQueueMappings(lambda.New(conf.Session), alias, nil, conf); err != nil {
+		return err
+	}
+
+	if err := removeStaleSubscriptions(alias, nil, conf); err != nil {
+		return err
+	}
+
+	buses := map[string]bool{"default": true}
+	for _, event := range functionEvents(conf) {
+		source, enabled := event.Source[conf.Environment]
+		if !enabled {
+			continue
+		}
+
+		switch event.Type {
+		case "s3":
+			if err := putBucketNotifications(fn, alias, source, nil, conf); err != nil {
+				return fmt.Errorf("unable to remove notifications of bucket '%v': %w", source, err)
+			}
+		case "eventbridge":
+			buses[source] = true
+		}
+	}
+
+	return removeEventRules(buses, nil, conf)
+}
+
+func deleteRule(client *cwe.CloudWatchEvents, name string, conf *Config) error {
+	exists, err := ruleExists(client, name)
+	if err != nil || !exists {
+		return err
+	}
+
+	deleted(conf, "rule", name)
+	_, err = client.RemoveTargets(&cwe.RemoveTargetsInput{
+		Rule: aws.String(name),
+		Ids:  aws.StringSlice([]string{conf.Environment}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DeleteRule(&cwe.DeleteRuleInput{Name: aws.String(name)})
+	return err
+}
+
+// deleteStage removes the stage of the current environment, and its usage plan along with
+// the API keys of the plan.
+func deleteStage(client *ag.APIGateway, conf *Config) error {
+	api, err := getAPI(client, conf)
+	if err != nil || api == nil {
+		return err
+	}
+
+	plan, err := getUsagePlan(client, conf)
+	if err != nil {
+		return err
+	}
+
+	if plan != nil {
+		var keys []*ag.UsagePlanKey
+		err = client.GetUsagePlanKeysPages(&ag.GetUsagePlanKeysInput{
+			UsagePlanId: plan.Id,
+			Limit:       aws.Int64(500),
+		}, func(page *ag.GetUsagePlanKeysOutput, last bool) bool {
+			keys = append(keys, page.Items...)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			deleted(conf, "API key", aws.StringValue(key.Name))
+			_, err = client.DeleteApiKey(&ag.DeleteApiKeyInput{ApiKey: key.Id})
+			if err != nil && !isNotFound(err) {
+				return err
+			}
+		}
+
+		deleted(conf, "usage plan", usagePlanName(conf))
+		// A plan can't be deleted while it is attached to a stage.
+		if planAttached(plan, api, conf) {
+			if err = detachUsagePlan(client, plan, api, conf); err != nil && !isNotFound(err) {
+				return err
+			}
+		}
+
+		if _, err = client.DeleteUsagePlan(&ag.DeleteUsagePlanInput{UsagePlanId: plan.Id}); err != nil {
+			return err
+		}
+	}
+
+	deleted(conf, "stage", conf.Environment)
+	_, err = client.DeleteStage(&ag.DeleteStageInput{
+		RestApiId: api.Id,
+		StageName: aws.String(conf.Environment),
+	})
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// deleteBucket empties the bucket and removes it.
+func deleteBucket(client *s3.S3, bucket string, conf *Config) error {
+	var keys []*s3.ObjectIdentifier
+	err := client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, &s3.ObjectIdentifier{Key: object.Key})
+		}
+		return true
+	})
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// DeleteObjects takes at most 1000 keys per call.
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > 1000 {
+			n = 1000
+		}
+
+		_, err = client.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3.Delete{Objects: keys[:n], Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+
+	deleted(conf, "bucket", bucket)
+	_, err = client.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+	return err
+}
+
+// deleteShared removes the API, the functions and the roles launch created for them.
+func deleteShared(fns map[string]*lambda.FunctionConfiguration, confs []*Config, conf *Config) error {
+	agClient := ag.New(conf.Session)
+	api, err := getAPI(agClient, conf)
+	if err != nil {
+		return err
+	}
+
+	if api != nil {
+		deleted(conf, "API", apiName(conf))
+		if _, err = agClient.DeleteRestApi(&ag.DeleteRestApiInput{RestApiId: api.Id}); err != nil {
+			return err
+		}
+	}
+
+	client := lambda.New(conf.Session)
+	for _, c := range confs {
+		if _, exists := fns[c.Name]; !exists {
+			continue
+		}
+
+		deleted(c, "function", c.Name)
+		_, err = client.DeleteFunction(&lambda.DeleteFunctionInput{FunctionName: aws.String(c.Name)})
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+
+	roles := []string{}
+	for _, c := range confs {
+		if c.Roles.Lambda == "" {
+			roles = append(roles, lambdaRoleName(c))
+		}
+	}
+	if conf.Roles.API == "" {
+		roles = append(roles, apiRoleName(conf), staticRoleName(conf))
+	}
+
+	iamClient := iam.New(conf.Session)
+	for _, role := range roles {
+		if err = deleteRole(iamClient, role, conf); err != nil {
+			return fmt.Errorf("unable to remove role '%v': %w", role, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteRole removes a role along with its inline policies, after detaching its managed
+// policies.
+func deleteRole(client *iam.IAM, role string, conf *Config) error {
+	existing, err := getRole(client, role)
+	if err != nil || existing == nil {
+		return err
+	}
+
+	var policies []string
+	err = client.ListRolePoliciesPages(&iam.ListRolePoliciesInput{
+		RoleName: aws.String(role),
+	}, func(page *iam.ListRolePoliciesOutput, last bool) bool {
+		policies = append(policies, aws.StringValueSlice(page.PolicyNames)...)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, policy := range policies {
+		_, err = client.DeleteRolePolicy(&iam.DeleteRolePolicyInput{
+			RoleName:   aws.String(role),
+			PolicyName: aws.String(policy),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	attached, err := attachedPolicies(client, role)
+	if err != nil {
+		return err
+	}
+
+	for arn := range attached {
+		_, err = client.DetachRolePolicy(&iam.DetachRolePolicyInput{
+			RoleName:  aws.String(role),
+			PolicyArn: aws.String(arn),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	deleted(conf, "role", role)
+	_, err = client.DeleteRole(&iam.DeleteRoleInput{RoleName: aws.String(role)})
+	return err
+}
@@ -0,0 +1,180 @@
+package launch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// NotFoundError is returned when AWS reports that a resource doesn't exist.
+type NotFoundError struct {
+	Err error
+}
+
+func (e *NotFoundError) Error() string { return e.Err.Error() }
+func (e *NotFoundError) Unwrap() error { return e.Err }
+
+// PermissionError is returned when the credentials aren't allowed to call AWS. Action is
+// the IAM action that was denied, when it is known.
+type PermissionError struct {
+	Action string
+	Err    error
+}
+
+func (e *PermissionError) Error() string { return e.Err.Error() }
+func (e *PermissionError) Unwrap() error { return e.Err }
+
+// ThrottledError is returned when AWS kept throttling a call after it was retried.
+type ThrottledError struct {
+	Err error
+}
+
+func (e *ThrottledError) Error() string { return e.Err.Error() }
+func (e *ThrottledError) Unwrap() error { return e.Err }
+
+// ValidationError is returned when AWS rejects the input of a call, and when the config
+// file can't be read.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// AccountError is returned when the credentials belong to another account than the one
+// declared for the environment.
+type AccountError struct {
+	Environment string
+	Expected    string
+	Actual      string
+}
+
+func (e *AccountError) Error() string {
+	return fmt.Sprintf("'%v' is deployed to account %v, but the credentials belong to account %v", e.Environment, e.Expected, e.Actual)
+}
+
+// CanaryAbortedError is returned when a canary had too many errors, and traffic was routed
+// back to the stable version.
+type CanaryAbortedError struct {
+	Version string
+	Errors  float64
+}
+
+func (e *CanaryAbortedError) Error() string {
+	return fmt.Sprintf("canary of version %v aborted after %v errors", e.Version, e.Errors)
+}
+
+var notFoundCodes = map[string]bool{
+	"ResourceNotFoundException": true,
+	"NotFoundException":         true,
+	"NoSuchEntity":              true,
+	"NotFound":                  true,
+	"NoSuchBucket":              true,
+	"NoSuchKey":                 true,
+}
+
+var permissionCodes = map[string]bool{
+	"AccessDenied":                true,
+	"AccessDeniedException":       true,
+	"UnauthorizedOperation":       true,
+	"UnauthorizedException":       true,
+	"AuthorizationError":          true,
+	"Forbidden":                   true,
+	"InvalidClientTokenId":        true,
+	"UnrecognizedClientException": true,
+	"ExpiredToken":                true,
+	"ExpiredTokenException":       true,
+}
+
+var validationCodes = map[string]bool{
+	"ValidationError":                true,
+	"ValidationException":            true,
+	"InvalidParameterValueException": true,
+	"InvalidParameterException":      true,
+	"InvalidRequestException":        true,
+	"BadRequestException":            true,
+	"MalformedPolicyDocument":        true,
+	"InvalidInput":                   true,
+	request.InvalidParameterErrCode:  true,
+}
+
+// deniedActionPattern finds the action in messages like 'User: ... is not authorized to
+// perform: lambda:GetFunction on resource: ...'.
+var deniedActionPattern = regexp.MustCompile(`perform: ([\w-]+:[\w*-]+)`)
+
+// classify wraps an AWS error in the type matching its code. Other errors are returned as
+// they are. Action is the IAM action of the call, used when the message doesn't name one.
+func classify(err error, action string) error {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return err
+	}
+
+	switch code := aerr.Code(); {
+	case notFoundCodes[code]:
+		return &NotFoundError{Err: err}
+	case permissionCodes[code]:
+		if match := deniedActionPattern.FindStringSubmatch(aerr.Message()); match != nil {
+			action = match[1]
+		}
+		return &PermissionError{Action: action, Err: err}
+	case request.IsErrorThrottle(aerr):
+		return &ThrottledError{Err: err}
+	case validationCodes[code]:
+		return &ValidationError{Err: err}
+	}
+
+	return err
+}
+
+// classifyErrors is added to every session, so calls that fail for good return one of the
+// error types above. Errors that are about to be retried have been cleared at this point.
+var classifyErrors = request.NamedHandler{
+	Name: "launch.ClassifyErrors",
+	Fn: func(r *request.Request) {
+		if r.Error != nil {
+			r.Error = classify(r.Error, requestAction(r))
+		}
+	},
+}
+
+// requestAction returns the IAM action of a request, e.g. lambda:GetFunction.
+func requestAction(r *request.Request) string {
+	service := r.ClientInfo.SigningName
+	if service == "" {
+		service = r.ClientInfo.ServiceName
+	}
+	return fmt.Sprintf("%v:%v", service, r.Operation.Name)
+}
+
+func isNotFound(err error) bool {
+	var notFound *NotFoundError
+	return errors.As(classify(err, ""), &notFound)
+}
+
+// CheckTabs returns a ValidationError naming the first line of the config file that is
+// indented with a tab. YAML only allows spaces.
+func CheckTabs(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+		if strings.Contains(indent, "\t") {
+			return &ValidationError{Err: fmt.Errorf("line %v of '%v' is indented with a tab, YAML only allows spaces", line, path)}
+		}
+	}
+
+	return scanner.Err()
+}
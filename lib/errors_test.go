@@ -0,0 +1,87 @@
+package launch
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   interface{}
+		action string
+	}{
+		{"not found", awserr.New("ResourceNotFoundException", "Function not found", nil), &NotFoundError{}, ""},
+		{"no such entity", awserr.New("NoSuchEntity", "Role not found", nil), &NotFoundError{}, ""},
+		{
+			"denied action in the message",
+			awserr.New("AccessDeniedException", "User: arn:aws:iam::123456789012:user/ci is not authorized to perform: lambda:GetFunction on resource: app", nil),
+			&PermissionError{},
+			"lambda:GetFunction",
+		},
+		{"denied call", awserr.New("AccessDenied", "Access Denied", nil), &PermissionError{}, "s3:PutObject"},
+		{"throttled", awserr.New("TooManyRequestsException", "Rate exceeded", nil), &ThrottledError{}, ""},
+		{"rejected input", awserr.New("ValidationException", "1 validation error", nil), &ValidationError{}, ""},
+		{"wrapped", fmt.Errorf("error creating API: %w", awserr.New("BadRequestException", "Invalid name", nil)), &ValidationError{}, ""},
+		{"unknown code", awserr.New("ServiceException", "Internal error", nil), nil, ""},
+		{"not from AWS", errors.New("disk full"), nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classify(tt.err, "s3:PutObject")
+
+			if tt.want == nil {
+				if err != tt.err {
+					t.Fatalf("expected the error to be returned as it is, got %T", err)
+				}
+				return
+			}
+
+			if reflect.TypeOf(err) != reflect.TypeOf(tt.want) {
+				t.Fatalf("expected %T, got %T", tt.want, err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected the error to wrap %v", tt.err)
+			}
+
+			var permission *PermissionError
+			if errors.As(err, &permission) && permission.Action != tt.action {
+				t.Errorf("expected action %q, got %q", tt.action, permission.Action)
+			}
+		})
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if !isNotFound(awserr.New("NotFoundException", "Invalid stage identifier", nil)) {
+		t.Error("expected NotFoundException to be a not found error")
+	}
+	if isNotFound(awserr.New("ConflictException", "Stage already exists", nil)) {
+		t.Error("expected ConflictException not to be a not found error")
+	}
+	if isNotFound(nil) {
+		t.Error("expected nil not to be a not found error")
+	}
+}
+
+func TestCheckTabs(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "spaces.yml", "name: app\nstage:\n  dev:\n    tracing: true\n")
+	writeFile(t, dir, "tabs.yml", "name: app\nstage:\n\tdev:\n")
+
+	if err := CheckTabs(filepath.Join(dir, "spaces.yml")); err != nil {
+		t.Errorf("expected no error for a file indented with spaces, got %v", err)
+	}
+
+	var validation *ValidationError
+	if err := CheckTabs(filepath.Join(dir, "tabs.yml")); !errors.As(err, &validation) {
+		t.Errorf("expected a ValidationError for a file indented with tabs, got %v", err)
+	}
+}
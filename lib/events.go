@@ -12,17 +12,22 @@ func CreateOrUpdateFunctionWarmer(fn *lambda.FunctionConfiguration, conf *Config
 
 	arn, err := createRule(client, conf)
 	if err != nil {
-		return fmt.Errorf("unable to create cloudwatch event: %v", err)
+		return fmt.Errorf("unable to create cloudwatch event: %w", err)
 	}
 
-	err = addEventPermission(arn, conf)
+	err = addEventPermission(arn, conf.Environment, conf)
 	if err != nil {
-		return fmt.Errorf("unable to give cloudwatch events access to lambda: %v", err)
+		return fmt.Errorf("unable to give cloudwatch events access to lambda: %w", err)
 	}
 
 	err = addTarget(client, fn, conf)
 	if err != nil {
-		return fmt.Errorf("unable to add lambda as event target: %v", err)
+		return fmt.Errorf("unable to add lambda as event target: %w", err)
+	}
+
+	err = tagRule(client, arn, fn, conf)
+	if err != nil {
+		return fmt.Errorf("unable to tag cloudwatch event: %w", err)
 	}
 
 	return nil
@@ -47,7 +52,7 @@ func addTarget(client *cwe.CloudWatchEvents, fn *lambda.FunctionConfiguration, c
 			{
 				Id:    aws.String(conf.Environment),
 				Arn:   aws.String(fmt.Sprintf("%v:%v", lambdaRootARN(*fn.FunctionArn, conf), conf.Environment)),
-				Input: aws.String(input(conf)),
+				Input: aws.String(warmerInput),
 			},
 		},
 	})
@@ -58,13 +63,10 @@ func ruleName(conf *Config) string {
 	return fmt.Sprintf("%v-%v-warmer", conf.Name, conf.Environment)
 }
 
-func input(conf *Config) string {
-	return fmt.Sprintf(`{
-		"resource": "/{proxy+}",
-		"path": "/",
-		"httpMethod": "GET",
-		"stageVariables": {
-			"environment": "%v"
-		}
-	}`, conf.Environment)
-}
+// warmerInput is the event the warmer sends. Without stage variables, the shim reads
+// them from the stage of the alias it was invoked through.
+const warmerInput = `{
+	"resource": "/{proxy+}",
+	"path": "/",
+	"httpMethod": "GET"
+}`
@@ -0,0 +1,480 @@
+package launch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	cwe "github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+var eventTypes = []string{"sqs", "sns", "s3", "eventbridge"}
+
+// CreateOrUpdateEventSources subscribes the functions of the app, and of its routes, to
+// the queues, topics, buckets and event buses configured for the current environment.
+func CreateOrUpdateEventSources(fn *lambda.FunctionConfiguration, routes map[string]*lambda.FunctionConfiguration, conf *Config) error {
+	if err := createOrUpdateEventSources(fn, conf); err != nil {
+		return err
+	}
+
+	for _, route := range conf.Routes {
+		if err := createOrUpdateEventSources(routes[route.Name], RouteConfig(route, conf)); err != nil {
+			return fmt.Errorf("error subscribing route '%v' to events: %w", route.Path, err)
+		}
+	}
+
+	return nil
+}
+
+func createOrUpdateEventSources(fn *lambda.FunctionConfiguration, conf *Config) error {
+	events := functionEvents(conf)
+	alias := aliasARN(fn, conf)
+	client := lambda.New(conf.Session)
+
+	buckets := map[string][]Event{}
+	for _, event := range events {
+		source, enabled := event.Source[conf.Environment]
+		if !enabled {
+			continue
+		}
+
+		var err error
+		switch event.Type {
+		case "sqs":
+			err = getOrCreateQueueMapping(client, alias, source, event, conf)
+		case "sns":
+			err = getOrCreateSubscription(alias, source, conf)
+		case "s3":
+			buckets[source] = append(buckets[source], event)
+		case "eventbridge":
+			err = getOrCreateEventRule(fn, alias, source, event, conf)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to subscribe to %v event '%v': %w", event.Type, event.Name, err)
+		}
+	}
+
+	for bucket, bucketEvents := range buckets {
+		if err := putBucketNotifications(fn, alias, bucket, bucketEvents, conf); err != nil {
+			return fmt.Errorf("unable to set up notifications of bucket '%v': %w", bucket, err)
+		}
+	}
+
+	if err := removeStaleQueueMappings(client, alias, events, conf); err != nil {
+		return err
+	}
+
+	if err := removeStaleSubscriptions(alias, events, conf); err != nil {
+		return err
+	}
+
+	if err := removeStaleBucketNotifications(fn, alias, buckets, conf); err != nil {
+		return err
+	}
+
+	return removeStaleEventRules(events, conf)
+}
+
+// functionEvents returns the events handled by the function of conf, leaving out those
+// whose path belongs to a route.
+func functionEvents(conf *Config) []Event {
+	var events []Event
+	for _, event := range conf.Events {
+		if _, isRoute := pathRoute(event.Path, conf); !isRoute {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// eventSources returns the sources of all environments for events of the given type.
+func eventSources(events []Event, eventType string) []string {
+	var sources []string
+	for _, event := range events {
+		if event.Type != eventType {
+			continue
+		}
+		for _, source := range event.Source {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// getOrCreateQueueMapping makes the alias poll the queue. Batch item failures are reported
+// by the shim, so only failed messages are retried.
+func getOrCreateQueueMapping(client *lambda.Lambda, alias, queue string, event Event, conf *Config) error {
+	batchSize := event.BatchSize
+	if batchSize == 0 {
+		batchSize = 10
+	}
+
+	mappings, err := client.ListEventSourceMappings(&lambda.ListEventSourceMappingsInput{
+		FunctionName:   aws.String(alias),
+		EventSourceArn: aws.String(queue),
+	})
+	if err != nil {
+		return err
+	}
+
+	responseTypes := aws.StringSlice([]string{lambda.FunctionResponseTypeReportBatchItemFailures})
+
+	if len(mappings.EventSourceMappings) > 0 {
+		mapping := mappings.EventSourceMappings[0]
+		if aws.Int64Value(mapping.BatchSize) == batchSize && len(mapping.FunctionResponseTypes) > 0 {
+			return nil
+		}
+
+		updated(conf, "subscription", queue, "")
+		_, err = client.UpdateEventSourceMapping(&lambda.UpdateEventSourceMappingInput{
+			UUID:                  mapping.UUID,
+			BatchSize:             aws.Int64(batchSize),
+			FunctionResponseTypes: responseTypes,
+		})
+		return err
+	}
+
+	created(conf, "subscription", queue, "")
+	_, err = client.CreateEventSourceMapping(&lambda.CreateEventSourceMappingInput{
+		FunctionName:          aws.String(alias),
+		EventSourceArn:        aws.String(queue),
+		BatchSize:             aws.Int64(batchSize),
+		FunctionResponseTypes: responseTypes,
+	})
+	return err
+}
+
+func removeStaleQueueMappings(client *lambda.Lambda, alias string, events []Event, conf *Config) error {
+	queues := map[string]bool{}
+	for _, event := range events {
+		if event.Type == "sqs" && event.Source[conf.Environment] != "" {
+			queues[event.Source[conf.Environment]] = true
+		}
+	}
+
+	var stale []*lambda.EventSourceMappingConfiguration
+	err := client.ListEventSourceMappingsPages(&lambda.ListEventSourceMappingsInput{
+		FunctionName: aws.String(alias),
+	}, func(page *lambda.ListEventSourceMappingsOutput, last bool) bool {
+		for _, mapping := range page.EventSourceMappings {
+			arn := aws.StringValue(mapping.EventSourceArn)
+			if strings.HasPrefix(arn, "arn:aws:sqs:") && !queues[arn] {
+				stale = append(stale, mapping)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list queue subscriptions: %w", err)
+	}
+
+	for _, mapping := range stale {
+		deleted(conf, "subscription", *mapping.EventSourceArn)
+		_, err = client.DeleteEventSourceMapping(&lambda.DeleteEventSourceMappingInput{
+			UUID: mapping.UUID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getOrCreateSubscription(alias, topic string, conf *Config) error {
+	err := addPermission(&lambda.AddPermissionInput{
+		Principal:   aws.String("sns.amazonaws.com"),
+		SourceArn:   aws.String(topic),
+		StatementId: aws.String(topicStatementID(topic, conf)),
+	}, conf)
+	if err != nil {
+		return err
+	}
+
+	// Subscribing is idempotent, and returns the existing subscription if there is one.
+	_, err = sns.New(conf.Session).Subscribe(&sns.SubscribeInput{
+		TopicArn: aws.String(topic),
+		Protocol: aws.String("lambda"),
+		Endpoint: aws.String(alias),
+	})
+	return err
+}
+
+func removeStaleSubscriptions(alias string, events []Event, conf *Config) error {
+	client := sns.New(conf.Session)
+
+	topics := map[string]bool{}
+	for _, event := range events {
+		if event.Type == "sns" && event.Source[conf.Environment] != "" {
+			topics[event.Source[conf.Environment]] = true
+		}
+	}
+
+	var stale []*sns.Subscription
+	err := client.ListSubscriptionsPages(&sns.ListSubscriptionsInput{}, func(page *sns.ListSubscriptionsOutput, last bool) bool {
+		for _, sub := range page.Subscriptions {
+			if aws.StringValue(sub.Endpoint) == alias && !topics[aws.StringValue(sub.TopicArn)] {
+				stale = append(stale, sub)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list topic subscriptions: %w", err)
+	}
+
+	for _, sub := range stale {
+		deleted(conf, "subscription", *sub.TopicArn)
+		if _, err = client.Unsubscribe(&sns.UnsubscribeInput{SubscriptionArn: sub.SubscriptionArn}); err != nil {
+			return err
+		}
+
+		lambda.New(conf.Session).RemovePermission(&lambda.RemovePermissionInput{
+			FunctionName: aws.String(conf.Name),
+			StatementId:  aws.String(topicStatementID(*sub.TopicArn, conf)),
+			Qualifier:    aws.String(conf.Environment),
+		})
+	}
+
+	return nil
+}
+
+// putBucketNotifications replaces the notifications launch owns on a bucket, keeping any
+// other notifications as they are. Without events, the notifications are removed.
+func putBucketNotifications(fn *lambda.FunctionConfiguration, alias, bucket string, events []Event, conf *Config) error {
+	client := s3.New(conf.Session)
+
+	if len(events) > 0 {
+		err := addPermission(&lambda.AddPermissionInput{
+			Principal:     aws.String("s3.amazonaws.com"),
+			SourceArn:     aws.String("arn:aws:s3:::" + bucket),
+			SourceAccount: aws.String(accountID(*fn.FunctionArn)),
+			StatementId:   aws.String(bucketStatementID(bucket, conf)),
+		}, conf)
+		if err != nil {
+			return err
+		}
+	}
+
+	notifications, err := client.GetBucketNotificationConfiguration(&s3.GetBucketNotificationConfigurationRequest{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return err
+	}
+
+	var configs []*s3.LambdaFunctionConfiguration
+	for _, config := range notifications.LambdaFunctionConfigurations {
+		if !strings.HasPrefix(aws.StringValue(config.Id), notificationID("", conf)) {
+			configs = append(configs, config)
+		}
+	}
+
+	for _, event := range events {
+		types := event.S3Events
+		if len(types) == 0 {
+			types = []string{s3.EventS3ObjectCreated}
+		}
+
+		config := &s3.LambdaFunctionConfiguration{
+			Id:                aws.String(notificationID(event.Name, conf)),
+			LambdaFunctionArn: aws.String(alias),
+			Events:            aws.StringSlice(types),
+		}
+
+		var rules []*s3.FilterRule
+		if event.Prefix != "" {
+			rules = append(rules, &s3.FilterRule{Name: aws.String(s3.FilterRuleNamePrefix), Value: aws.String(event.Prefix)})
+		}
+		if event.Suffix != "" {
+			rules = append(rules, &s3.FilterRule{Name: aws.String(s3.FilterRuleNameSuffix), Value: aws.String(event.Suffix)})
+		}
+		if len(rules) > 0 {
+			config.Filter = &s3.NotificationConfigurationFilter{Key: &s3.KeyFilter{FilterRules: rules}}
+		}
+
+		configs = append(configs, config)
+	}
+
+	notifications.LambdaFunctionConfigurations = configs
+
+	updated(conf, "bucket", bucket, "notifications")
+	_, err = client.PutBucketNotificationConfiguration(&s3.PutBucketNotificationConfigurationInput{
+		Bucket:                    aws.String(bucket),
+		NotificationConfiguration: notifications,
+	})
+	return err
+}
+
+// removeStaleBucketNotifications removes the notifications of buckets that are no longer
+// configured. Those buckets are found through the permissions they were given on the alias.
+func removeStaleBucketNotifications(fn *lambda.FunctionConfiguration, alias string, buckets map[string][]Event, conf *Config) error {
+	statements, err := aliasPermissions(conf)
+	if err != nil {
+		return fmt.Errorf("unable to list bucket permissions: %w", err)
+	}
+
+	for _, statement := range statements {
+		bucket := strings.TrimPrefix(statement.sourceARN(), "arn:aws:s3:::")
+		if !strings.HasPrefix(statement.Sid, bucketStatementID("", conf)) || bucket == statement.sourceARN() {
+			continue
+		}
+		if _, current := buckets[bucket]; current {
+			continue
+		}
+
+		deleted(conf, "subscription", bucket)
+		err = putBucketNotifications(fn, alias, bucket, nil, conf)
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("unable to remove notifications of bucket '%v': %w", bucket, err)
+		}
+
+		if err = removePermission(statement.Sid, conf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getOrCreateEventRule sends the events matching the pattern to the alias. The event is
+// wrapped with the name of the launch event, so the shim knows where to deliver it.
+func getOrCreateEventRule(fn *lambda.FunctionConfiguration, alias, bus string, event Event, conf *Config) error {
+	client := cwe.New(conf.Session)
+
+	updated(conf, "event rule", eventRuleName(event.Name, conf), "")
+	rule, err := client.PutRule(&cwe.PutRuleInput{
+		Name:         aws.String(eventRuleName(event.Name, conf)),
+		EventBusName: aws.String(bus),
+		EventPattern: aws.String(event.Pattern),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = addEventPermission(rule.RuleArn, eventStatementID(event.Name, conf), conf); err != nil {
+		return err
+	}
+
+	_, err = client.PutTargets(&cwe.PutTargetsInput{
+		Rule:         aws.String(eventRuleName(event.Name, conf)),
+		EventBusName: aws.String(bus),
+		Targets: []*cwe.Target{
+			{
+				Id:  aws.String(conf.Environment),
+				Arn: aws.String(alias),
+				InputTransformer: &cwe.InputTransformer{
+					InputTemplate: aws.String(fmt.Sprintf(`{"launchEvent": "%v", "event": <aws.events.event.json>}`, event.Name)),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return tagRule(client, rule.RuleArn, fn, conf)
+}
+
+// removeStaleEventRules removes the rules of events that are no longer configured. Only
+// the buses of configured events are searched.
+func removeStaleEventRules(events []Event, conf *Config) error {
+	current := map[string]bool{}
+	buses := map[string]bool{"default": true}
+	for _, event := range events {
+		if event.Type == "eventbridge" && event.Source[conf.Environment] != "" {
+			current[eventRuleName(event.Name, conf)] = true
+			buses[event.Source[conf.Environment]] = true
+		}
+	}
+
+	return removeEventRules(buses, current, conf)
+}
+
+// removeEventRules removes the event rules of the current environment on the given buses,
+// except for the current ones.
+func removeEventRules(buses, current map[string]bool, conf *Config) error {
+	client := cwe.New(conf.Session)
+
+	for bus := range buses {
+		input := &cwe.ListRulesInput{
+			NamePrefix:   aws.String(eventRuleName("", conf)),
+			EventBusName: aws.String(bus),
+		}
+
+		for {
+			page, err := client.ListRules(input)
+			if err != nil {
+				return fmt.Errorf("unable to list event rules: %w", err)
+			}
+
+			for _, rule := range page.Rules {
+				if current[*rule.Name] {
+					continue
+				}
+
+				name := strings.TrimPrefix(*rule.Name, eventRuleName("", conf))
+				deleted(conf, "event rule", *rule.Name)
+
+				_, err = client.RemoveTargets(&cwe.RemoveTargetsInput{
+					Rule:         rule.Name,
+					EventBusName: aws.String(bus),
+					Ids:          aws.StringSlice([]string{conf.Environment}),
+				})
+				if err != nil {
+					return err
+				}
+
+				_, err = client.DeleteRule(&cwe.DeleteRuleInput{
+					Name:         rule.Name,
+					EventBusName: aws.String(bus),
+				})
+				if err != nil {
+					return err
+				}
+
+				lambda.New(conf.Session).RemovePermission(&lambda.RemovePermissionInput{
+					FunctionName: aws.String(conf.Name),
+					StatementId:  aws.String(eventStatementID(name, conf)),
+					Qualifier:    aws.String(conf.Environment),
+				})
+			}
+
+			if page.NextToken == nil {
+				break
+			}
+			input.NextToken = page.NextToken
+		}
+	}
+
+	return nil
+}
+
+func aliasARN(fn *lambda.FunctionConfiguration, conf *Config) string {
+	return fmt.Sprintf("%v:%v", lambdaRootARN(*fn.FunctionArn, conf), conf.Environment)
+}
+
+func eventRuleName(name string, conf *Config) string {
+	return fmt.Sprintf("%v-%v-event-%v", conf.Name, conf.Environment, name)
+}
+
+func eventStatementID(name string, conf *Config) string {
+	return fmt.Sprintf("%v-event-%v", conf.Environment, name)
+}
+
+// topicStatementID and bucketStatementID are derived from the source, since stale sources
+// are found without their event.
+func topicStatementID(topic string, conf *Config) string {
+	return fmt.Sprintf("%v-sns-%v", conf.Environment, topic[strings.LastIndex(topic, ":")+1:])
+}
+
+func bucketStatementID(bucket string, conf *Config) string {
+	return fmt.Sprintf("%v-s3-%v", conf.Environment, strings.Replace(bucket, ".", "-", -1))
+}
+
+func notificationID(name string, conf *Config) string {
+	return fmt.Sprintf("launch:%v:%v:%v", conf.Name, conf.Environment, name)
+}
@@ -0,0 +1,50 @@
+package launch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFunctionEvents(t *testing.T) {
+	conf := &Config{
+		Routes: []Route{{Path: "/admin/{proxy+}", Name: "admin"}},
+		Events: []Event{
+			{Name: "orders", Type: "sqs", Path: "/orders"},
+			{Name: "audit", Type: "sns", Path: "/admin/audit"},
+			{Name: "uploads", Type: "s3", Path: "/administration"},
+		},
+	}
+
+	var got []string
+	for _, event := range functionEvents(conf) {
+		got = append(got, event.Name)
+	}
+
+	want := []string{"orders", "uploads"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEventSources(t *testing.T) {
+	events := []Event{
+		{Type: "sqs", Source: map[string]string{"dev": "arn:dev-queue"}},
+		{Type: "sns", Source: map[string]string{"dev": "arn:dev-topic"}},
+		{Type: "sqs", Source: map[string]string{"prod": "arn:prod-queue"}},
+	}
+
+	tests := []struct {
+		eventType string
+		want      []string
+	}{
+		{"sqs", []string{"arn:dev-queue", "arn:prod-queue"}},
+		{"sns", []string{"arn:dev-topic"}},
+		{"s3", nil},
+	}
+
+	for _, tt := range tests {
+		if got := eventSources(events, tt.eventType); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.eventType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package launch
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Hooks are shell commands run during a deployment. PrePackage runs before the app is
+// zipped, PreAliasSwitch after the new version is published but before the alias of the
+// environment moves to it, and PostDeploy once the stage serves the new version.
+type Hooks struct {
+	PrePackage     []string `yaml:"pre-package,omitempty" mapstructure:"pre-package"`
+	PreAliasSwitch []string `yaml:"pre-alias-switch,omitempty" mapstructure:"pre-alias-switch"`
+	PostDeploy     []string `yaml:"post-deploy,omitempty" mapstructure:"post-deploy"`
+}
+
+func (h Hooks) byName() map[string][]string {
+	return map[string][]string{
+		"pre-package":      h.PrePackage,
+		"pre-alias-switch": h.PreAliasSwitch,
+		"post-deploy":      h.PostDeploy,
+	}
+}
+
+// hookWaitDelay is how long a hook's output is read after the hook is killed.
+const hookWaitDelay = time.Second
+
+// HookError is returned when a hook command exits with an error. The commands after it
+// aren't run.
+type HookError struct {
+	Hook    string
+	Command string
+	Err     error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%v hook '%v' failed: %v", e.Hook, e.Command, e.Err)
+}
+
+func (e *HookError) Unwrap() error { return e.Err }
+
+// runHook runs the commands of a hook in order with 'sh -c', in the environment of launch
+// with env added. Their output is passed on as Info notices. A command still running when
+// the deployment is cancelled is killed.
+func runHook(hook string, commands []string, env map[string]string, conf *Config) error {
+	for _, command := range commands {
+		info(conf, "Running %v hook '%v'", hook, command)
+
+		out := &hookOutput{hook: hook, conf: conf}
+		cmd := exec.CommandContext(conf.runContext(), "sh", "-c", command)
+		killProcessGroup(cmd)
+		// Stop waiting on the output of children that outlive the command.
+		cmd.WaitDelay = hookWaitDelay
+		cmd.Env = os.Environ()
+		for k, v := range env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", k, v))
+		}
+		cmd.Stdout = out
+		cmd.Stderr = out
+
+		err := cmd.Run()
+		out.flush()
+		if err != nil {
+			return &HookError{Hook: hook, Command: command, Err: err}
+		}
+	}
+	return nil
+}
+
+// hookEnv describes the deployment to hooks. The fields of result are only set once they
+// are known.
+func hookEnv(result *Result, conf *Config) map[string]string {
+	env := map[string]string{
+		"LAUNCH_NAME":        conf.Name,
+		"LAUNCH_ENVIRONMENT": conf.Environment,
+		"LAUNCH_REGION":      conf.Region,
+	}
+
+	if result == nil {
+		return env
+	}
+
+	for k, v := range map[string]string{
+		"LAUNCH_ACCOUNT":       result.Account,
+		"LAUNCH_FUNCTION_ARN":  result.FunctionARN,
+		"LAUNCH_VERSION":       result.Version,
+		"LAUNCH_ALIAS_ARN":     result.Alias,
+		"LAUNCH_API_ID":        result.APIID,
+		"LAUNCH_STAGE":         result.Stage,
+		"LAUNCH_DEPLOYMENT_ID": result.DeploymentID,
+		"LAUNCH_URL":           result.URL,
+	} {
+		if v != "" {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// hookOutput passes the output of a hook on line by line.
+type hookOutput struct {
+	hook string
+	conf *Config
+	buf  bytes.Buffer
+}
+
+func (o *hookOutput) Write(p []byte) (int, error) {
+	o.buf.Write(p)
+	for {
+		line, err := o.buf.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line until the rest of it is written.
+			o.buf.WriteString(line)
+			return len(p), nil
+		}
+		info(o.conf, "[%v] %v", o.hook, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+func (o *hookOutput) flush() {
+	if o.buf.Len() > 0 {
+		info(o.conf, "[%v] %v", o.hook, o.buf.String())
+		o.buf.Reset()
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package launch
+
+import "os/exec"
+
+// killProcessGroup leaves the command as it is. Only the command itself is killed when it
+// is cancelled.
+func killProcessGroup(cmd *exec.Cmd) {}
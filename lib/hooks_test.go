@@ -0,0 +1,87 @@
+package launch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// hookMessages returns a config whose listener records the messages of Info notices.
+func hookMessages() (*Config, *[]string) {
+	var messages []string
+	conf := &Config{
+		Name:        "app",
+		Environment: "dev",
+		Listener: ListenerFunc(func(n Notice) {
+			if i, isInfo := n.(Info); isInfo {
+				messages = append(messages, i.Message)
+			}
+		}),
+	}
+	return conf, &messages
+}
+
+func TestRunHook(t *testing.T) {
+	conf, messages := hookMessages()
+	env := hookEnv(&Result{Version: "7"}, conf)
+
+	err := runHook("post-deploy", []string{`echo "$LAUNCH_ENVIRONMENT $LAUNCH_VERSION"; printf partial`}, env, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`Running post-deploy hook 'echo "$LAUNCH_ENVIRONMENT $LAUNCH_VERSION"; printf partial'`,
+		"[post-deploy] dev 7",
+		"[post-deploy] partial",
+	}
+	if !reflect.DeepEqual(*messages, want) {
+		t.Errorf("got %q, want %q", *messages, want)
+	}
+}
+
+func TestRunHookStopsOnFailure(t *testing.T) {
+	conf, messages := hookMessages()
+
+	err := runHook("pre-package", []string{"exit 3", "echo never"}, nil, conf)
+
+	var hookErr *HookError
+	if !errors.As(err, &hookErr) || hookErr.Hook != "pre-package" || hookErr.Command != "exit 3" {
+		t.Fatalf("expected the first command to fail the hook, got %v", err)
+	}
+	if len(*messages) != 1 {
+		t.Errorf("expected the commands after a failure not to run, got %q", *messages)
+	}
+}
+
+func TestRunHookKilledWhenCancelled(t *testing.T) {
+	conf, _ := hookMessages()
+	ctx, cancel := context.WithCancel(context.Background())
+	conf.ctx = ctx
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := runHook("post-deploy", []string{"sleep 10"}, nil, conf); err == nil {
+		t.Fatal("expected a cancelled hook to fail")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("expected the command to be killed when the deployment was cancelled")
+	}
+}
+
+func TestHookEnv(t *testing.T) {
+	conf := &Config{Name: "app", Environment: "dev", Region: "eu-west-1"}
+
+	want := map[string]string{"LAUNCH_NAME": "app", "LAUNCH_ENVIRONMENT": "dev", "LAUNCH_REGION": "eu-west-1"}
+	if got := hookEnv(nil, conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	want["LAUNCH_VERSION"] = "7"
+	want["LAUNCH_URL"] = "https://example.com/dev"
+	if got := hookEnv(&Result{Version: "7", URL: "https://example.com/dev"}, conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected only known fields of the result, got %v, want %v", got, want)
+	}
+}
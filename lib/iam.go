@@ -1,7 +1,10 @@
 package launch
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -9,9 +12,22 @@ import (
 	l "github.com/aws/aws-sdk-go/service/lambda"
 )
 
+var cloudWatchRoleName = "launch-apigateway-logs-role"
+
+const (
+	lambdaPrincipal     = "lambda.amazonaws.com"
+	apiGatewayPrincipal = "apigateway.amazonaws.com"
+)
+
+// GetOrCreateLambdaRole returns the role of the function, which is the configured role
+// when there is one.
 func GetOrCreateLambdaRole(conf *Config) (*iam.Role, error) {
 	client := iam.New(conf.Session)
 
+	if conf.Roles.Lambda != "" {
+		return getExistingRole(client, conf.Roles.Lambda)
+	}
+
 	role, err := getRole(client, lambdaRoleName(conf))
 	if err != nil {
 		return nil, err
@@ -21,24 +37,183 @@ func GetOrCreateLambdaRole(conf *Config) (*iam.Role, error) {
 		return role, nil
 	}
 
-	fmt.Printf("Creating service role named '%v'\n", lambdaRoleName(conf))
-	return createLambdaRole(client, conf)
+	created(conf, "role", lambdaRoleName(conf), "")
+	return createRole(client, lambdaRoleName(conf), lambdaPrincipal, resourceTags(conf), conf)
 }
 
-func GetOrCreateAPIRole(fn *l.FunctionConfiguration, conf *Config) (*iam.Role, error) {
+// GetOrCreateAPIRole returns the role API Gateway uses to invoke the functions. Its policy
+// is checked on every run, in case functions have been added, removed or replaced. The
+// policies of a configured role are left alone.
+func GetOrCreateAPIRole(fns []*l.FunctionConfiguration, conf *Config) (*iam.Role, error) {
 	client := iam.New(conf.Session)
 
+	if conf.Roles.API != "" {
+		return getExistingRole(client, conf.Roles.API)
+	}
+
 	role, err := getRole(client, apiRoleName(conf))
 	if err != nil {
 		return nil, err
 	}
 
+	if role == nil {
+		created(conf, "role", apiRoleName(conf), "")
+		role, err = createRole(client, apiRoleName(conf), apiGatewayPrincipal, resourceTags(conf), conf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tagRole(client, *role.RoleName, conf); err != nil {
+		return nil, err
+	}
+
+	return role, putAPIRolePolicy(client, role, fns, conf)
+}
+
+// GetOrCreateCloudWatchRole returns the role API Gateway uses to write logs. It is shared
+// by every API in the account and region, and is the configured role when there is one.
+func GetOrCreateCloudWatchRole(conf *Config) (*iam.Role, error) {
+	client := iam.New(conf.Session)
+
+	if conf.Roles.CloudWatch != "" {
+		return getExistingRole(client, conf.Roles.CloudWatch)
+	}
+	name := conf.Roles.NamePrefix + cloudWatchRoleName
+
+	role, err := getRole(client, name)
+	if err != nil {
+		return nil, err
+	}
+
 	if role != nil {
 		return role, nil
 	}
 
-	fmt.Printf("Creating service role named '%v'\n", apiRoleName(conf))
-	return createAPIRole(client, fn, conf)
+	created(conf, "role", name, "")
+	role, err = createRole(client, name, apiGatewayPrincipal, nil, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = client.AttachRolePolicy(&iam.AttachRolePolicyInput{
+		RoleName:  role.RoleName,
+		PolicyArn: aws.String("arn:aws:iam::aws:policy/service-role/AmazonAPIGatewayPushToCloudWatchLogs"),
+	})
+
+	return role, err
+}
+
+// GetOrCreateStaticRole returns the role API Gateway uses to read static files from the
+// buckets of every environment. A configured API role is used for this as well.
+func GetOrCreateStaticRole(fn *l.FunctionConfiguration, conf *Config) (*iam.Role, error) {
+	client := iam.New(conf.Session)
+
+	if conf.Roles.API != "" {
+		return getExistingRole(client, conf.Roles.API)
+	}
+
+	role, err := getRole(client, staticRoleName(conf))
+	if err != nil {
+		return nil, err
+	}
+
+	if role != nil {
+		return role, tagRole(client, *role.RoleName, conf)
+	}
+
+	created(conf, "role", staticRoleName(conf), "")
+	return createStaticRole(client, fn, conf)
+}
+
+// CheckRoles makes sure that the configured roles exist, and that their trust policies
+// let the services assume them.
+func CheckRoles(conf *Config) error {
+	client := iam.New(conf.Session)
+
+	roles := []struct {
+		arn, principal, section string
+	}{
+		{conf.Roles.Lambda, lambdaPrincipal, "lambda"},
+		{conf.Roles.API, apiGatewayPrincipal, "api"},
+		{conf.Roles.CloudWatch, apiGatewayPrincipal, "cloudwatch"},
+	}
+
+	for _, r := range roles {
+		if r.arn == "" {
+			continue
+		}
+
+		role, err := getExistingRole(client, r.arn)
+		if err != nil {
+			return err
+		}
+
+		trusted, err := trusts(role, r.principal)
+		if err != nil {
+			return fmt.Errorf("unable to read the trust policy of role '%v': %w", r.arn, err)
+		}
+
+		if !trusted {
+			return fmt.Errorf(
+				"role '%v' in 'roles.%v' can't be used, its trust policy must allow %v to call sts:AssumeRole",
+				r.arn, r.section, r.principal,
+			)
+		}
+	}
+
+	if conf.Roles.Lambda != "" {
+		return checkStageAccess(client, conf.Roles.Lambda, conf)
+	}
+
+	return nil
+}
+
+// checkStageAccess makes sure the lambda role can read the stage variables, which the shim
+// does when an event, a schedule or the warmer boots the app. Credentials that may not
+// simulate policies only get a warning.
+func checkStageAccess(client *iam.IAM, arn string, conf *Config) error {
+	out, err := client.SimulatePrincipalPolicy(&iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(arn),
+		ActionNames:     aws.StringSlice([]string{"apigateway:GET"}),
+		ResourceArns: aws.StringSlice([]string{
+			fmt.Sprintf("arn:aws:apigateway:%v::/restapis", conf.Region),
+			fmt.Sprintf("arn:aws:apigateway:%v::/restapis/*/stages/%v", conf.Region, conf.Environment),
+		}),
+	})
+
+	var denied *PermissionError
+	if errors.As(err, &denied) {
+		warn(conf, "unable to check that role '%v' can read stage variables: %v", arn, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to check the policies of role '%v': %w", arn, err)
+	}
+
+	for _, result := range out.EvaluationResults {
+		if aws.StringValue(result.EvalDecision) != iam.PolicyEvaluationDecisionTypeAllowed {
+			return fmt.Errorf(
+				"role '%v' in 'roles.lambda' can't be used, it must allow apigateway:GET on '%v' to read stage variables",
+				arn, aws.StringValue(result.EvalResourceName),
+			)
+		}
+	}
+
+	return nil
+}
+
+func getExistingRole(client *iam.IAM, arn string) (*iam.Role, error) {
+	role, err := getRole(client, roleNameFromARN(arn))
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return nil, fmt.Errorf("role '%v' does not exist", arn)
+	}
+
+	return role, nil
 }
 
 func getRole(client *iam.IAM, roleName string) (*iam.Role, error) {
@@ -46,7 +221,7 @@ func getRole(client *iam.IAM, roleName string) (*iam.Role, error) {
 		RoleName: aws.String(roleName),
 	})
 
-	if err != nil && !strings.Contains(err.Error(), "NoSuchEntity") {
+	if err != nil && !isNotFound(err) {
 		return nil, err
 	}
 
@@ -57,112 +232,195 @@ func getRole(client *iam.IAM, roleName string) (*iam.Role, error) {
 	return role.Role, nil
 }
 
-func createLambdaRole(client *iam.IAM, conf *Config) (*iam.Role, error) {
-	role, err := client.CreateRole(&iam.CreateRoleInput{
-		RoleName: aws.String(lambdaRoleName(conf)),
-		Path:     aws.String("/service-role/"),
-		AssumeRolePolicyDocument: aws.String(`{
+// trusts tells whether the trust policy of a role lets the service principal assume it.
+func trusts(role *iam.Role, principal string) (bool, error) {
+	document, err := url.QueryUnescape(aws.StringValue(role.AssumeRolePolicyDocument))
+	if err != nil {
+		return false, err
+	}
+
+	var policy struct {
+		Statement []struct {
+			Effect    string
+			Action    interface{}
+			Principal struct {
+				Service interface{}
+			}
+		}
+	}
+	if err = json.Unmarshal([]byte(document), &policy); err != nil {
+		return false, err
+	}
+
+	for _, statement := range policy.Statement {
+		if statement.Effect == "Allow" &&
+			contains(stringOrList(statement.Action), "sts:AssumeRole") &&
+			contains(stringOrList(statement.Principal.Service), principal) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// stringOrList returns the values of a policy element, which may be a string or a list.
+func stringOrList(v interface{}) []string {
+	switch value := v.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// createRole creates a role the service can assume, with the path and permissions
+// boundary of the config.
+func createRole(client *iam.IAM, name, principal string, tags map[string]string, conf *Config) (*iam.Role, error) {
+	path := conf.Roles.Path
+	if path == "" {
+		path = "/service-role/"
+	}
+
+	input := &iam.CreateRoleInput{
+		RoleName: aws.String(name),
+		Path:     aws.String(path),
+		AssumeRolePolicyDocument: aws.String(fmt.Sprintf(`{
   		"Version": "2012-10-17",
   		"Statement": [
     		{
       		"Effect": "Allow",
       		"Principal": {
-        		"Service": "lambda.amazonaws.com"
+        		"Service": "%v"
       		},
       		"Action": "sts:AssumeRole"
     		}
   		]
-		}`),
-	})
+		}`, principal)),
+	}
+
+	if conf.Roles.Boundary != "" {
+		input.PermissionsBoundary = aws.String(conf.Roles.Boundary)
+	}
+
+	if len(tags) > 0 {
+		input.Tags = iamTags(tags)
+	}
 
+	role, err := client.CreateRole(input)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = client.PutRolePolicy(&iam.PutRolePolicyInput{
-		PolicyName: aws.String(lambdaPolicyName(conf)),
-		RoleName:   role.Role.RoleName,
-		PolicyDocument: aws.String(fmt.Sprintf(`{
-    	"Version": "2012-10-17",
-    	"Statement": [
-        {
-					"Effect": "Allow",
-					"Action": "logs:CreateLogGroup",
-					"Resource": "arn:aws:logs:%v:*:*"
-        },
-        {
-					"Effect": "Allow",
-					"Action": [
-						"logs:CreateLogStream",
-						"logs:PutLogEvents"
-					],
-					"Resource": [
-						"arn:aws:logs:%v:*:log-group:/aws/lambda/%v:*"
-					]
-        }
-    	]
-		}`, conf.Region, conf.Region, conf.Name)),
-	})
-
-	return role.Role, err
+	return role.Role, nil
 }
 
-func createAPIRole(client *iam.IAM, fn *l.FunctionConfiguration, conf *Config) (*iam.Role, error) {
-	role, err := client.CreateRole(&iam.CreateRoleInput{
-		RoleName: aws.String(apiRoleName(conf)),
-		Path:     aws.String("/service-role/"),
-		AssumeRolePolicyDocument: aws.String(`{
+func putAPIRolePolicy(client *iam.IAM, role *iam.Role, fns []*l.FunctionConfiguration, conf *Config) error {
+	var resources []string
+	for _, fn := range fns {
+		resources = append(resources, fmt.Sprintf(`"%v:*"`, unqualifiedARN(fn)))
+	}
+
+	return syncRolePolicy(client, *role.RoleName, apiPolicyName(conf), fmt.Sprintf(`{
   		"Version": "2012-10-17",
   		"Statement": [
     		{
       		"Effect": "Allow",
-      		"Principal": {
-        		"Service": "apigateway.amazonaws.com"
-      		},
-      		"Action": "sts:AssumeRole"
+      		"Resource": [
+        		%v
+      		],
+      		"Action": [
+        		"lambda:InvokeFunction"
+      		]
     		}
   		]
-		}`),
-	})
+		}`, strings.Join(resources, ", ")), conf)
+}
 
+func createStaticRole(client *iam.IAM, fn *l.FunctionConfiguration, conf *Config) (*iam.Role, error) {
+	role, err := createRole(client, staticRoleName(conf), apiGatewayPrincipal, resourceTags(conf), conf)
 	if err != nil {
 		return nil, err
 	}
 
 	_, err = client.PutRolePolicy(&iam.PutRolePolicyInput{
-		RoleName:   role.Role.RoleName,
-		PolicyName: aws.String(apiPolicyName(conf)),
+		RoleName:   role.RoleName,
+		PolicyName: aws.String(staticPolicyName(conf)),
 		PolicyDocument: aws.String(fmt.Sprintf(`{
   		"Version": "2012-10-17",
   		"Statement": [
     		{
       		"Effect": "Allow",
       		"Resource": [
-        		"%v:*"
+        		"arn:aws:s3:::%v-*-static-%v/*"
       		],
       		"Action": [
-        		"lambda:InvokeFunction"
+        		"s3:GetObject"
       		]
     		}
   		]
-		}`, *fn.FunctionArn)),
+		}`, strings.ToLower(conf.Name), accountID(*fn.FunctionArn))),
 	})
 
-	return role.Role, err
+	return role, err
 }
 
+// lambdaRoleName returns the name of the function role, which is the name of the
+// configured role when there is one.
 func lambdaRoleName(conf *Config) string {
-	return conf.Name + "-lambda-role"
+	if conf.Roles.Lambda != "" {
+		return roleNameFromARN(conf.Roles.Lambda)
+	}
+	return conf.Roles.NamePrefix + conf.Name + "-lambda-role"
 }
 
 func apiRoleName(conf *Config) string {
-	return conf.Name + "-api-role"
+	return conf.Roles.NamePrefix + conf.Name + "-api-role"
+}
+
+func staticRoleName(conf *Config) string {
+	return conf.Roles.NamePrefix + conf.Name + "-static-role"
+}
+
+// roleNameFromARN returns the name of a role, which follows its path in the ARN.
+func roleNameFromARN(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+func staticPolicyName(conf *Config) string {
+	return conf.Name + "-static-read-access"
 }
 
 func lambdaPolicyName(conf *Config) string {
 	return conf.Name + "-log-access"
 }
 
+func stageVariablesPolicyName(conf *Config) string {
+	return conf.Name + "-stage-variables"
+}
+
+func customPolicyName(conf *Config) string {
+	return conf.Name + "-custom-policy"
+}
+
+func vpcPolicyName(conf *Config) string {
+	return conf.Name + "-vpc-access"
+}
+
+func asyncPolicyName(conf *Config) string {
+	return conf.Name + "-async-destinations"
+}
+
+func eventSourcePolicyName(conf *Config) string {
+	return conf.Name + "-event-sources"
+}
+
 func apiPolicyName(conf *Config) string {
 	return conf.Name + "-lambda-invoke-access"
 }
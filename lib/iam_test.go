@@ -0,0 +1,52 @@
+package launch
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+func TestTrusts(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     bool
+	}{
+		{"service string", `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole",
+			"Principal": {"Service": "lambda.amazonaws.com"}}]}`, true},
+		{"service list", `{"Statement": [{"Effect": "Allow", "Action": ["sts:AssumeRole", "sts:TagSession"],
+			"Principal": {"Service": ["edgelambda.amazonaws.com", "lambda.amazonaws.com"]}}]}`, true},
+		{"other service", `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole",
+			"Principal": {"Service": "apigateway.amazonaws.com"}}]}`, false},
+		{"denied", `{"Statement": [{"Effect": "Deny", "Action": "sts:AssumeRole",
+			"Principal": {"Service": "lambda.amazonaws.com"}}]}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := &iam.Role{AssumeRolePolicyDocument: aws.String(url.QueryEscape(tt.document))}
+
+			got, err := trusts(role, "lambda.amazonaws.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoleNames(t *testing.T) {
+	conf := &Config{Name: "app", Roles: Roles{NamePrefix: "team-"}}
+	if got := lambdaRoleName(conf); got != "team-app-lambda-role" {
+		t.Errorf("got %v, want team-app-lambda-role", got)
+	}
+
+	conf.Roles.Lambda = "arn:aws:iam::123456789012:role/service/existing"
+	if got := lambdaRoleName(conf); got != "existing" {
+		t.Errorf("got %v, want existing", got)
+	}
+}
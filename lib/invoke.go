@@ -0,0 +1,148 @@
+package launch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Invocation is a request sent straight to the function serving Path, as an API Gateway
+// proxy event. Version selects a published version, the alias of the environment is
+// invoked when it is empty.
+type Invocation struct {
+	Method  string
+	Path    string
+	Headers map[string]string
+	Body    string
+	Version string
+}
+
+// InvokeResult is the response of the app to an Invocation, along with the last 4 KB of
+// the log of the invocation.
+type InvokeResult struct {
+	Function   string
+	Qualifier  string
+	Version    string
+	StatusCode int
+	Headers    map[string]string
+	Body       string
+	Log        string
+	Duration   time.Duration
+}
+
+// FunctionError is returned when the function fails instead of returning a response.
+// Payload holds the error as reported by Lambda.
+type FunctionError struct {
+	Function string
+	Type     string
+	Payload  string
+}
+
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("function '%v' failed (%v): %v", e.Function, e.Type, e.Payload)
+}
+
+// Invoke sends an invocation to the app function, or to the function of the route the path
+// belongs to, bypassing API Gateway. The result is returned along with a FunctionError, so
+// the log can be shown.
+func Invoke(inv Invocation, conf *Config) (*InvokeResult, error) {
+	client := lambda.New(conf.Session)
+
+	target := conf
+	if route, isRoute := pathRoute(inv.Path, conf); isRoute {
+		// Versions are numbered per function, so a version of the app function means
+		// nothing to a route function.
+		if inv.Version != "" {
+			return nil, fmt.Errorf("'%v' belongs to route '%v', only paths of the app function can be sent to a version", inv.Path, route.Name)
+		}
+		target = RouteConfig(route, conf)
+	}
+
+	vars, err := StageVariables(conf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the stage variables of '%v': %w", conf.Environment, err)
+	}
+
+	payload, err := invocationEvent(inv, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	qualifier := conf.Environment
+	if inv.Version != "" {
+		qualifier = inv.Version
+	}
+
+	start := time.Now()
+	out, err := client.Invoke(&lambda.InvokeInput{
+		FunctionName:   aws.String(target.Name),
+		Qualifier:      aws.String(qualifier),
+		InvocationType: aws.String(lambda.InvocationTypeRequestResponse),
+		LogType:        aws.String(lambda.LogTypeTail),
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to invoke '%v:%v': %w", target.Name, qualifier, err)
+	}
+
+	result := &InvokeResult{
+		Function:  target.Name,
+		Qualifier: qualifier,
+		Version:   aws.StringValue(out.ExecutedVersion),
+		Duration:  time.Since(start),
+	}
+
+	if out.LogResult != nil {
+		log, err := base64.StdEncoding.DecodeString(*out.LogResult)
+		if err == nil {
+			result.Log = string(log)
+		}
+	}
+
+	if out.FunctionError != nil {
+		return result, &FunctionError{Function: target.Name, Type: *out.FunctionError, Payload: string(out.Payload)}
+	}
+
+	var response struct {
+		StatusCode int               `json:"statusCode"`
+		Headers    map[string]string `json:"headers"`
+		Body       string            `json:"body"`
+	}
+	if err = json.Unmarshal(out.Payload, &response); err != nil {
+		return result, fmt.Errorf("unable to decode the response of '%v': %w", target.Name, err)
+	}
+
+	result.StatusCode = response.StatusCode
+	result.Headers = response.Headers
+	result.Body = response.Body
+	return result, nil
+}
+
+// invocationEvent builds the proxy event API Gateway would send for an invocation, given
+// the variables of the stage.
+func invocationEvent(inv Invocation, vars map[string]string) ([]byte, error) {
+	event := proxyEvent{
+		Resource:       "/{proxy+}",
+		HTTPMethod:     strings.ToUpper(inv.Method),
+		Headers:        inv.Headers,
+		Body:           inv.Body,
+		StageVariables: vars,
+	}
+
+	if event.HTTPMethod == "" {
+		event.HTTPMethod = "GET"
+	}
+
+	var err error
+	event.Path, event.QueryStringParameters, err = splitQuery(inv.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query in '%v': %w", inv.Path, err)
+	}
+
+	return json.Marshal(event)
+}
@@ -0,0 +1,130 @@
+package launch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// fakeFunctions serves the API and stage of the app, and answers invocations with the
+// proxy event they were sent, or with a function error when failing is set.
+type fakeFunctions struct {
+	invoked   string
+	qualifier string
+	event     map[string]interface{}
+	failing   bool
+}
+
+func (f *fakeFunctions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/restapis":
+		fmt.Fprint(w, `{"item": [{"id": "api", "name": "app-api"}]}`)
+	case r.URL.Path == "/restapis/api/stages/dev":
+		fmt.Fprint(w, `{"stageName": "dev", "variables": {"environment": "dev", "bucket": "uploads"}}`)
+	case strings.HasPrefix(r.URL.Path, "/2015-03-31/functions/"):
+		f.invoked = strings.Split(r.URL.Path, "/")[3]
+		f.qualifier = r.URL.Query().Get("Qualifier")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &f.event)
+
+		w.Header().Set("X-Amz-Executed-Version", "4")
+		w.Header().Set("X-Amz-Log-Result", base64.StdEncoding.EncodeToString([]byte("START RequestId: 1")))
+		if f.failing {
+			w.Header().Set("X-Amz-Function-Error", "Unhandled")
+			fmt.Fprint(w, `{"errorMessage": "boom"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"statusCode": 201,
+			"headers":    map[string]string{"Content-Type": "text/plain"},
+			"body":       "created",
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func invokeConfig(t *testing.T, functions *fakeFunctions) *Config {
+	server := httptest.NewServer(functions)
+	t.Cleanup(server.Close)
+
+	return &Config{
+		Name:        "app",
+		Environment: "dev",
+		Routes:      []Route{{Name: "admin", Path: "/admin"}},
+		Session: session.Must(session.NewSession(&aws.Config{
+			Region:      aws.String("us-east-1"),
+			Endpoint:    aws.String(server.URL),
+			Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+			MaxRetries:  aws.Int(0),
+		})),
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	functions := &fakeFunctions{}
+	conf := invokeConfig(t, functions)
+
+	result, err := Invoke(Invocation{Method: "post", Path: "/items?limit=5", Body: "{}", Version: "4"}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if functions.invoked != "app" || functions.qualifier != "4" {
+		t.Errorf("expected version 4 of app to be invoked, got %v:%v", functions.invoked, functions.qualifier)
+	}
+	if functions.event["httpMethod"] != "POST" || functions.event["path"] != "/items" {
+		t.Errorf("expected a POST to /items, got %v", functions.event)
+	}
+	if vars, _ := functions.event["stageVariables"].(map[string]interface{}); vars["bucket"] != "uploads" {
+		t.Errorf("expected the variables of the stage, got %v", functions.event["stageVariables"])
+	}
+
+	if result.StatusCode != 201 || result.Body != "created" || result.Headers["Content-Type"] != "text/plain" {
+		t.Errorf("expected the response of the app, got %+v", result)
+	}
+	if result.Version != "4" || result.Log != "START RequestId: 1" {
+		t.Errorf("expected the executed version and log, got %+v", result)
+	}
+}
+
+func TestInvokeRoute(t *testing.T) {
+	functions := &fakeFunctions{}
+	conf := invokeConfig(t, functions)
+
+	if _, err := Invoke(Invocation{Path: "/admin/users"}, conf); err != nil {
+		t.Fatal(err)
+	}
+	if functions.invoked != "app-admin" || functions.qualifier != "dev" {
+		t.Errorf("expected the dev alias of the route function to be invoked, got %v:%v", functions.invoked, functions.qualifier)
+	}
+
+	functions.invoked = ""
+	if _, err := Invoke(Invocation{Path: "/admin/users", Version: "4"}, conf); err == nil || functions.invoked != "" {
+		t.Errorf("expected a version of a route path to be rejected before invoking, got %v", err)
+	}
+}
+
+func TestInvokeFunctionError(t *testing.T) {
+	conf := invokeConfig(t, &fakeFunctions{failing: true})
+
+	result, err := Invoke(Invocation{Path: "/"}, conf)
+
+	var failed *FunctionError
+	if !errors.As(err, &failed) || failed.Type != "Unhandled" || !strings.Contains(failed.Payload, "boom") {
+		t.Fatalf("expected a FunctionError, got %v", err)
+	}
+	if result == nil || result.Log == "" {
+		t.Errorf("expected the log to be returned with the error, got %+v", result)
+	}
+}
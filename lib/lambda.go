@@ -1,9 +1,9 @@
 package launch
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/lambda"
@@ -20,10 +20,10 @@ func CreateOrUpdateFunction(conf *Config) (*lambda.FunctionConfiguration, error)
 	}
 
 	if exists {
-		fmt.Printf("Updating '%v'\n", conf.Name)
+		updated(conf, "function", conf.Name, "")
 		fn, err = updateFunction(client, conf)
 	} else {
-		fmt.Printf("Creating '%v'\n", conf.Name)
+		created(conf, "function", conf.Name, "")
 		fn, err = createFunction(client, conf)
 	}
 
@@ -31,26 +31,96 @@ func CreateOrUpdateFunction(conf *Config) (*lambda.FunctionConfiguration, error)
 		return nil, err
 	}
 
-	return fn, createOrUpdateAlias(client, fn, conf)
+	if err = runHook("pre-alias-switch", conf.Hooks.PreAliasSwitch, hookEnv(&Result{
+		FunctionARN: unqualifiedARN(fn),
+		Version:     *fn.Version,
+		Alias:       aliasARN(fn, conf),
+	}, conf), conf); err != nil {
+		return nil, err
+	}
+
+	if err = createOrUpdateAlias(client, fn, conf); err != nil {
+		return nil, err
+	}
+
+	if err = putAsyncConfig(client, conf); err != nil {
+		return nil, err
+	}
+
+	return fn, tagFunction(client, fn, conf)
+}
+
+func addEventPermission(eventArn *string, statementID string, conf *Config) error {
+	return addPermission(&lambda.AddPermissionInput{
+		Principal:   aws.String("events.amazonaws.com"),
+		SourceArn:   eventArn,
+		StatementId: aws.String(statementID),
+	}, conf)
 }
 
-func addEventPermission(eventArn *string, conf *Config) error {
+// addPermission lets the principal of input invoke the alias of the current environment,
+// replacing any earlier statement with the same ID.
+func addPermission(input *lambda.AddPermissionInput, conf *Config) error {
 	client := lambda.New(conf.Session)
 
 	client.RemovePermission(&lambda.RemovePermissionInput{
 		FunctionName: aws.String(conf.Name),
-		StatementId:  aws.String(conf.Environment),
+		StatementId:  input.StatementId,
+		Qualifier:    aws.String(conf.Environment),
+	})
+
+	input.FunctionName = aws.String(conf.Name)
+	input.Action = aws.String("lambda:InvokeFunction")
+	input.Qualifier = aws.String(conf.Environment)
+
+	_, err := client.AddPermission(input)
+	return err
+}
+
+// permissionStatement is a statement of the resource policy of an alias.
+type permissionStatement struct {
+	Sid       string
+	Condition map[string]map[string]interface{}
+}
+
+// sourceARN returns the resource the statement lets invoke the alias, if any.
+func (s permissionStatement) sourceARN() string {
+	arn, _ := s.Condition["ArnLike"]["AWS:SourceArn"].(string)
+	return arn
+}
+
+// aliasPermissions returns the statements of the resource policy of the alias of the
+// current environment.
+func aliasPermissions(conf *Config) ([]permissionStatement, error) {
+	out, err := lambda.New(conf.Session).GetPolicy(&lambda.GetPolicyInput{
+		FunctionName: aws.String(conf.Name),
 		Qualifier:    aws.String(conf.Environment),
 	})
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var policy struct {
+		Statement []permissionStatement
+	}
+	if err = json.Unmarshal([]byte(aws.StringValue(out.Policy)), &policy); err != nil {
+		return nil, fmt.Errorf("unable to read the policy of '%v:%v': %w", conf.Name, conf.Environment, err)
+	}
+	return policy.Statement, nil
+}
 
-	_, err := client.AddPermission(&lambda.AddPermissionInput{
+func removePermission(statementID string, conf *Config) error {
+	_, err := lambda.New(conf.Session).RemovePermission(&lambda.RemovePermissionInput{
 		FunctionName: aws.String(conf.Name),
-		Action:       aws.String("lambda:InvokeFunction"),
-		Principal:    aws.String("events.amazonaws.com"),
-		SourceArn:    eventArn,
-		StatementId:  aws.String(conf.Environment),
+		StatementId:  aws.String(statementID),
 		Qualifier:    aws.String(conf.Environment),
 	})
+	if isNotFound(err) {
+		return nil
+	}
 	return err
 }
 
@@ -60,7 +130,7 @@ func getFunction(client *lambda.Lambda, conf *Config) (bool, error) {
 	})
 
 	if err != nil {
-		if strings.Contains(err.Error(), "NotFound") {
+		if isNotFound(err) {
 			return false, nil
 		}
 		return false, err
@@ -75,7 +145,15 @@ func updateFunction(client *lambda.Lambda, conf *Config) (*lambda.FunctionConfig
 		return nil, err
 	}
 
-	fmt.Println("Uploading...")
+	if err = SyncLambdaRolePolicies(conf); err != nil {
+		return nil, err
+	}
+
+	if err = updateFunctionConfiguration(client, conf); err != nil {
+		return nil, err
+	}
+
+	info(conf, "Uploading...")
 	return client.UpdateFunctionCode(&lambda.UpdateFunctionCodeInput{
 		FunctionName: aws.String(conf.Name),
 		Publish:      aws.Bool(true),
@@ -95,30 +173,84 @@ func createFunction(client *lambda.Lambda, conf *Config) (*lambda.FunctionConfig
 		return nil, err
 	}
 
-	fmt.Println("Uploading...")
-	upload := func() (*lambda.FunctionConfiguration, error) {
-		return client.CreateFunction(&lambda.CreateFunctionInput{
-			FunctionName: aws.String(conf.Name),
-			Publish:      aws.Bool(true),
-			Description:  aws.String(conf.Description),
-			Handler:      aws.String("launch_shim.proxy"),
-			Role:         role.Arn,
-			Runtime:      aws.String("nodejs4.3"),
-			Code: &lambda.FunctionCode{
-				ZipFile: bytes.Bytes(),
-			},
-		})
+	if err = SyncLambdaRolePolicies(conf); err != nil {
+		return nil, err
 	}
 
-	fn, err := upload()
+	var vpc *lambda.VpcConfig
+	if len(vpcSettings(conf).Subnets) > 0 {
+		vpc = vpcConfig(conf)
+	}
 
-	for err != nil && strings.Contains(err.Error(), "cannot be assumed by Lambda") {
-		fmt.Printf("Service role '%v' is not ready yet, retrying in 3s...\n", *role.RoleName)
-		time.Sleep(time.Second * 3)
-		fn, err = upload()
+	// A new role takes a while before Lambda is able to assume it, which the retryer of
+	// the session waits out.
+	info(conf, "Uploading...")
+	return client.CreateFunction(&lambda.CreateFunctionInput{
+		FunctionName: aws.String(conf.Name),
+		Publish:      aws.Bool(true),
+		Description:  aws.String(conf.Description),
+		Handler:      aws.String("launch_shim.proxy"),
+		Role:         role.Arn,
+		Runtime:      aws.String("nodejs4.3"),
+		Code: &lambda.FunctionCode{
+			ZipFile: bytes.Bytes(),
+		},
+		DeadLetterConfig: deadLetterConfig(conf),
+		VpcConfig:        vpc,
+		Tags:             aws.StringMap(resourceTags(conf)),
+	})
+}
+
+// updateFunctionConfiguration brings the settings of the function in line with the config
+// before new code is published, since a version keeps the settings it was published with.
+func updateFunctionConfiguration(client *lambda.Lambda, conf *Config) error {
+	current, err := client.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(conf.Name),
+	})
+	if err != nil {
+		return err
 	}
 
-	return fn, err
+	input := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(conf.Name),
+	}
+	changed := false
+
+	var dlq string
+	if current.DeadLetterConfig != nil {
+		dlq = aws.StringValue(current.DeadLetterConfig.TargetArn)
+	}
+	if dlq != conf.Async.DLQ {
+		input.DeadLetterConfig = &lambda.DeadLetterConfig{TargetArn: aws.String(conf.Async.DLQ)}
+		changed = true
+	}
+
+	if !vpcUpToDate(current.VpcConfig, conf) {
+		input.VpcConfig = vpcConfig(conf)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	updated(conf, "function", conf.Name, "configuration")
+	_, err = client.UpdateFunctionConfiguration(input)
+	if err != nil {
+		return err
+	}
+
+	// The code can't be updated while the configuration is.
+	return client.WaitUntilFunctionUpdated(&lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(conf.Name),
+	})
+}
+
+func deadLetterConfig(conf *Config) *lambda.DeadLetterConfig {
+	if conf.Async.DLQ == "" {
+		return nil
+	}
+	return &lambda.DeadLetterConfig{TargetArn: aws.String(conf.Async.DLQ)}
 }
 
 func createOrUpdateAlias(client *lambda.Lambda, fn *lambda.FunctionConfiguration, conf *Config) error {
@@ -128,10 +260,16 @@ func createOrUpdateAlias(client *lambda.Lambda, fn *lambda.FunctionConfiguration
 	}
 
 	if alias == nil {
-		fmt.Printf("Creating alias '%v' at version %v\n", conf.Environment, *fn.Version)
+		created(conf, "alias", conf.Environment, "version "+*fn.Version)
 		return createAlias(client, fn, conf)
+	} else if conf.Canary.Weight > 0 && *alias.FunctionVersion != *fn.Version {
+		updated(conf, "alias", conf.Environment, fmt.Sprintf(
+			"%v%% to version %v, the rest stays on version %v",
+			conf.Canary.Weight, *fn.Version, *alias.FunctionVersion,
+		))
+		return routeAlias(client, *alias.FunctionVersion, *fn.Version, conf.Canary.Weight, conf)
 	} else {
-		fmt.Printf("Updating alias '%v' to point to version %v\n", conf.Environment, *fn.Version)
+		updated(conf, "alias", conf.Environment, "version "+*fn.Version)
 		return updateAlias(client, fn, conf)
 	}
 }
@@ -142,7 +280,7 @@ func getAlias(client *lambda.Lambda, conf *Config) (*lambda.AliasConfiguration,
 		FunctionName: aws.String(conf.Name),
 	})
 
-	if err != nil && strings.Contains(err.Error(), "NotFound") {
+	if err != nil && isNotFound(err) {
 		return nil, nil
 	}
 
@@ -154,6 +292,25 @@ func updateAlias(client *lambda.Lambda, fn *lambda.FunctionConfiguration, conf *
 		Name:            aws.String(conf.Environment),
 		FunctionName:    aws.String(conf.Name),
 		FunctionVersion: fn.Version,
+		RoutingConfig: &lambda.AliasRoutingConfiguration{
+			AdditionalVersionWeights: map[string]*float64{},
+		},
+	})
+	return err
+}
+
+// routeAlias keeps the alias on the stable version, and sends weight percent of its
+// traffic to the canary version.
+func routeAlias(client *lambda.Lambda, stable, canary string, weight int, conf *Config) error {
+	_, err := client.UpdateAlias(&lambda.UpdateAliasInput{
+		Name:            aws.String(conf.Environment),
+		FunctionName:    aws.String(conf.Name),
+		FunctionVersion: aws.String(stable),
+		RoutingConfig: &lambda.AliasRoutingConfiguration{
+			AdditionalVersionWeights: map[string]*float64{
+				canary: aws.Float64(float64(weight) / 100),
+			},
+		},
 	})
 	return err
 }
@@ -172,3 +329,19 @@ func lambdaRootARN(arn string, conf *Config) string {
 	lastRelevantSegment := strings.LastIndex(arn, conf.Name)
 	return arn[:(lastRelevantSegment + len(conf.Name))]
 }
+
+// unqualifiedARN returns the ARN of a function without any version or alias.
+func unqualifiedARN(fn *lambda.FunctionConfiguration) string {
+	arn := *fn.FunctionArn
+	end := strings.Index(arn, ":function:") + len(":function:") + len(*fn.FunctionName)
+	return arn[:end]
+}
+
+// accountID returns the AWS account ID embedded in an ARN.
+func accountID(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 5 {
+		return ""
+	}
+	return parts[4]
+}
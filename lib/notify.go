@@ -0,0 +1,132 @@
+package launch
+
+import (
+	"fmt"
+	"time"
+)
+
+// Notice is emitted while launch works. It is one of StepStarted, StepFinished,
+// ResourceCreated, ResourceUpdated, ResourceDeleted, Info or Warning.
+type Notice interface {
+	String() string
+}
+
+// Listener receives the notices of a deployment. It is called from the goroutine doing
+// the work, so it shouldn't block.
+type Listener interface {
+	Notify(Notice)
+}
+
+// ListenerFunc lets an ordinary function be used as a Listener.
+type ListenerFunc func(Notice)
+
+func (f ListenerFunc) Notify(n Notice) { f(n) }
+
+// StepStarted is emitted when a Deployer starts a step, like 'function' or 'api'.
+type StepStarted struct {
+	Step string
+}
+
+func (s StepStarted) String() string {
+	return fmt.Sprintf("Starting %v", s.Step)
+}
+
+// StepFinished is emitted when a step is done. Err is set when the step failed.
+type StepFinished struct {
+	Step     string
+	Duration time.Duration
+	Err      error
+}
+
+func (s StepFinished) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("Failed %v after %v: %v", s.Step, s.Duration.Round(time.Millisecond), s.Err)
+	}
+	return fmt.Sprintf("Finished %v in %v", s.Step, s.Duration.Round(time.Millisecond))
+}
+
+// ResourceCreated is emitted when a resource is created. Type is the kind of resource, like
+// 'function' or 'role', and Detail adds to what was created, when there is more to tell.
+type ResourceCreated struct {
+	Type   string
+	Name   string
+	Detail string
+}
+
+func (r ResourceCreated) String() string {
+	return describe("Creating", r.Type, r.Name, r.Detail)
+}
+
+// ResourceUpdated is emitted when an existing resource is changed. Detail tells what was
+// changed, when only part of the resource was.
+type ResourceUpdated struct {
+	Type   string
+	Name   string
+	Detail string
+}
+
+func (r ResourceUpdated) String() string {
+	return describe("Updating", r.Type, r.Name, r.Detail)
+}
+
+// ResourceDeleted is emitted when a resource is removed.
+type ResourceDeleted struct {
+	Type string
+	Name string
+}
+
+func (r ResourceDeleted) String() string {
+	return describe("Removing", r.Type, r.Name, "")
+}
+
+// Info is emitted for progress that isn't about a single resource, like uploads and the
+// steps of a canary release.
+type Info struct {
+	Message string
+}
+
+func (i Info) String() string {
+	return i.Message
+}
+
+// Warning is emitted for problems that don't stop the deployment.
+type Warning struct {
+	Message string
+}
+
+func (w Warning) String() string {
+	return "Warning: " + w.Message
+}
+
+func describe(verb, resourceType, name, detail string) string {
+	if detail == "" {
+		return fmt.Sprintf("%v %v '%v'", verb, resourceType, name)
+	}
+	return fmt.Sprintf("%v %v '%v' (%v)", verb, resourceType, name, detail)
+}
+
+func notify(conf *Config, n Notice) {
+	if conf.Listener != nil {
+		conf.Listener.Notify(n)
+	}
+}
+
+func created(conf *Config, resourceType, name, detail string) {
+	notify(conf, ResourceCreated{Type: resourceType, Name: name, Detail: detail})
+}
+
+func updated(conf *Config, resourceType, name, detail string) {
+	notify(conf, ResourceUpdated{Type: resourceType, Name: name, Detail: detail})
+}
+
+func deleted(conf *Config, resourceType, name string) {
+	notify(conf, ResourceDeleted{Type: resourceType, Name: name})
+}
+
+func info(conf *Config, format string, args ...interface{}) {
+	notify(conf, Info{Message: fmt.Sprintf(format, args...)})
+}
+
+func warn(conf *Config, format string, args ...interface{}) {
+	notify(conf, Warning{Message: fmt.Sprintf(format, args...)})
+}
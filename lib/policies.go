@@ -0,0 +1,385 @@
+package launch
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// SyncLambdaRolePolicies brings the inline and managed policies of the function role in
+// line with the config. Inline policies that are no longer needed, and managed policies
+// that are no longer listed, are removed from the role. The policies of a configured role
+// are left alone, with a warning about the permissions it needs for the config.
+func SyncLambdaRolePolicies(conf *Config) error {
+	if conf.Roles.Lambda != "" {
+		var needs []string
+		if len(eventSources(functionEvents(conf), "sqs")) > 0 {
+			needs = append(needs, "reading the SQS queues of 'events'")
+		}
+		if len(asyncDestinations(conf)) > 0 {
+			needs = append(needs, "sending to the destinations of 'async'")
+		}
+		if vpcConfigured(conf) {
+			needs = append(needs, "managing the network interfaces of 'vpc'")
+		}
+		if len(needs) > 0 {
+			warn(conf, "the policies of 'roles.lambda' are left alone, make sure they allow %v", strings.Join(needs, ", "))
+		}
+		return nil
+	}
+
+	client := iam.New(conf.Session)
+	role := lambdaRoleName(conf)
+
+	desired, err := lambdaRolePolicies(conf)
+	if err != nil {
+		return err
+	}
+
+	for name, document := range desired {
+		if err = syncRolePolicy(client, role, name, document, conf); err != nil {
+			return fmt.Errorf("unable to update policy '%v': %w", name, err)
+		}
+	}
+
+	var stale []string
+	err = client.ListRolePoliciesPages(&iam.ListRolePoliciesInput{
+		RoleName: aws.String(role),
+	}, func(page *iam.ListRolePoliciesOutput, last bool) bool {
+		for _, name := range page.PolicyNames {
+			if _, keep := desired[*name]; !keep {
+				stale = append(stale, *name)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list policies of '%v': %w", role, err)
+	}
+
+	for _, name := range stale {
+		updated(conf, "role", role, fmt.Sprintf("removed policy '%v'", name))
+		_, err = client.DeleteRolePolicy(&iam.DeleteRolePolicyInput{
+			RoleName:   aws.String(role),
+			PolicyName: aws.String(name),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = syncManagedPolicies(client, role, conf.IAM.ManagedPolicies, conf); err != nil {
+		return err
+	}
+
+	return tagRole(client, role, conf)
+}
+
+// lambdaRolePolicies returns the inline policies of the function role, keyed by name.
+func lambdaRolePolicies(conf *Config) (map[string]string, error) {
+	policies := map[string]string{
+		lambdaPolicyName(conf):         logsPolicy(conf),
+		stageVariablesPolicyName(conf): stageVariablesPolicy(conf),
+	}
+
+	if queues := eventSources(functionEvents(conf), "sqs"); len(queues) > 0 {
+		policies[eventSourcePolicyName(conf)] = queuePolicy(queues)
+	}
+
+	if destinations := asyncDestinations(conf); len(destinations) > 0 {
+		policies[asyncPolicyName(conf)] = destinationPolicy(destinations)
+	}
+
+	if vpcConfigured(conf) {
+		policies[vpcPolicyName(conf)] = vpcPolicy
+	}
+
+	if len(conf.IAM.Statements) > 0 {
+		document, err := statementPolicy(conf.IAM.Statements)
+		if err != nil {
+			return nil, err
+		}
+		policies[customPolicyName(conf)] = document
+	}
+
+	return policies, nil
+}
+
+func logsPolicy(conf *Config) string {
+	return fmt.Sprintf(`{
+    	"Version": "2012-10-17",
+    	"Statement": [
+        {
+					"Effect": "Allow",
+					"Action": "logs:CreateLogGroup",
+					"Resource": "arn:aws:logs:%v:*:*"
+        },
+        {
+					"Effect": "Allow",
+					"Action": [
+						"logs:CreateLogStream",
+						"logs:PutLogEvents"
+					],
+					"Resource": [
+						"arn:aws:logs:%v:*:log-group:/aws/lambda/%v:*"
+					]
+        }
+    	]
+		}`, conf.Region, conf.Region, conf.Name)
+}
+
+// stageVariablesPolicy lets the shim read the variables of the stages, when the app is
+// booted by something other than a request through the API.
+func stageVariablesPolicy(conf *Config) string {
+	return fmt.Sprintf(`{
+  		"Version": "2012-10-17",
+  		"Statement": [
+    		{
+      		"Effect": "Allow",
+      		"Resource": [
+        		"arn:aws:apigateway:%v::/restapis",
+        		"arn:aws:apigateway:%v::/restapis/*/stages/*"
+      		],
+      		"Action": "apigateway:GET"
+    		}
+  		]
+		}`, conf.Region, conf.Region)
+}
+
+// queuePolicy lets the function poll the queues it is subscribed to, in every environment.
+func queuePolicy(queues []string) string {
+	var resources []string
+	for _, queue := range queues {
+		resources = append(resources, fmt.Sprintf(`"%v"`, queue))
+	}
+
+	return fmt.Sprintf(`{
+  		"Version": "2012-10-17",
+  		"Statement": [
+    		{
+      		"Effect": "Allow",
+      		"Resource": [
+        		%v
+      		],
+      		"Action": [
+        		"sqs:ReceiveMessage",
+        		"sqs:DeleteMessage",
+        		"sqs:ChangeMessageVisibility",
+        		"sqs:GetQueueAttributes"
+      		]
+    		}
+  		]
+		}`, strings.Join(resources, ", "))
+}
+
+// destinationPolicy lets the function send records to its destinations and dead-letter queue.
+func destinationPolicy(destinations []string) string {
+	var actions []string
+	resources := map[string][]string{}
+	for _, arn := range destinations {
+		action := destinationActions[arnService(arn)]
+		if _, seen := resources[action]; !seen {
+			actions = append(actions, action)
+		}
+		resources[action] = append(resources[action], fmt.Sprintf(`"%v"`, arn))
+	}
+
+	var statements []string
+	for _, action := range actions {
+		statements = append(statements, fmt.Sprintf(`
+    		{
+      		"Effect": "Allow",
+      		"Resource": [
+        		%v
+      		],
+      		"Action": "%v"
+    		}`, strings.Join(resources[action], ", "), action))
+	}
+
+	return fmt.Sprintf(`{
+  		"Version": "2012-10-17",
+  		"Statement": [%v
+  		]
+		}`, strings.Join(statements, ","))
+}
+
+// vpcPolicy lets Lambda manage the network interfaces of a function in a VPC.
+var vpcPolicy = `{
+  		"Version": "2012-10-17",
+  		"Statement": [
+    		{
+      		"Effect": "Allow",
+      		"Resource": "*",
+      		"Action": [
+        		"ec2:CreateNetworkInterface",
+        		"ec2:DescribeNetworkInterfaces",
+        		"ec2:DeleteNetworkInterface",
+        		"ec2:AssignPrivateIpAddresses",
+        		"ec2:UnassignPrivateIpAddresses"
+      		]
+    		}
+  		]
+		}`
+
+// statementPolicy returns a policy holding the statements of the iam section.
+func statementPolicy(statements []Statement) (string, error) {
+	policy := struct {
+		Version   string
+		Statement []Statement
+	}{Version: "2012-10-17"}
+
+	for _, statement := range statements {
+		if statement.Effect == "" {
+			statement.Effect = "Allow"
+		}
+		policy.Statement = append(policy.Statement, statement)
+	}
+
+	b, err := json.Marshal(policy)
+	return string(b), err
+}
+
+// syncRolePolicy puts an inline policy on a role, unless the role already has an
+// equivalent one.
+func syncRolePolicy(client *iam.IAM, role, name, document string, conf *Config) error {
+	current, err := client.GetRolePolicy(&iam.GetRolePolicyInput{
+		RoleName:   aws.String(role),
+		PolicyName: aws.String(name),
+	})
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	if current != nil && current.PolicyDocument != nil {
+		// Policy documents come back URL encoded.
+		decoded, err := url.QueryUnescape(*current.PolicyDocument)
+		if err == nil && samePolicy(decoded, document) {
+			return nil
+		}
+	}
+
+	updated(conf, "role", role, fmt.Sprintf("policy '%v'", name))
+	_, err = client.PutRolePolicy(&iam.PutRolePolicyInput{
+		RoleName:       aws.String(role),
+		PolicyName:     aws.String(name),
+		PolicyDocument: aws.String(document),
+	})
+	return err
+}
+
+// managedPolicyTagPrefix starts the role tags recording the managed policies launch
+// attached, so that policies attached by other means are never detached.
+const managedPolicyTagPrefix = "launch:policy:"
+
+func managedPolicyTag(arn string) string {
+	return fmt.Sprintf("%v%x", managedPolicyTagPrefix, sha1.Sum([]byte(arn)))[:len(managedPolicyTagPrefix)+12]
+}
+
+// syncManagedPolicies attaches the listed managed policies to a role, and detaches the
+// ones launch attached before that are no longer listed.
+func syncManagedPolicies(client *iam.IAM, role string, arns []string, conf *Config) error {
+	attached, err := attachedPolicies(client, role)
+	if err != nil {
+		return fmt.Errorf("unable to list managed policies of '%v': %w", role, err)
+	}
+
+	current, err := roleTags(client, role)
+	if err != nil {
+		return err
+	}
+
+	managed := map[string]string{}
+	for k, v := range current {
+		if strings.HasPrefix(k, managedPolicyTagPrefix) {
+			managed[v] = k
+		}
+	}
+
+	desired := map[string]string{}
+	for _, arn := range arns {
+		desired[managedPolicyTag(arn)] = arn
+		if attached[arn] {
+			continue
+		}
+
+		updated(conf, "role", role, fmt.Sprintf("attached '%v'", arn))
+		_, err = client.AttachRolePolicy(&iam.AttachRolePolicyInput{
+			RoleName:  aws.String(role),
+			PolicyArn: aws.String(arn),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var stale []string
+	for arn, key := range managed {
+		if _, keep := desired[key]; keep {
+			continue
+		}
+		stale = append(stale, key)
+
+		if !attached[arn] {
+			continue
+		}
+
+		updated(conf, "role", role, fmt.Sprintf("detached '%v'", arn))
+		_, err = client.DetachRolePolicy(&iam.DetachRolePolicyInput{
+			RoleName:  aws.String(role),
+			PolicyArn: aws.String(arn),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var changed []*iam.Tag
+	for k, v := range desired {
+		if current[k] != v {
+			changed = append(changed, &iam.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+	}
+
+	if len(changed) > 0 {
+		_, err = client.TagRole(&iam.TagRoleInput{RoleName: aws.String(role), Tags: changed})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(stale) > 0 {
+		_, err = client.UntagRole(&iam.UntagRoleInput{RoleName: aws.String(role), TagKeys: aws.StringSlice(stale)})
+	}
+
+	return err
+}
+
+// attachedPolicies returns the ARNs of the managed policies attached to a role.
+func attachedPolicies(client *iam.IAM, role string) (map[string]bool, error) {
+	attached := map[string]bool{}
+	err := client.ListAttachedRolePoliciesPages(&iam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(role),
+	}, func(page *iam.ListAttachedRolePoliciesOutput, last bool) bool {
+		for _, policy := range page.AttachedPolicies {
+			attached[*policy.PolicyArn] = true
+		}
+		return true
+	})
+
+	return attached, err
+}
+
+// samePolicy compares two policy documents, ignoring formatting.
+func samePolicy(a, b string) bool {
+	var x, y interface{}
+	if json.Unmarshal([]byte(a), &x) != nil || json.Unmarshal([]byte(b), &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
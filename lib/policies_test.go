@@ -0,0 +1,87 @@
+package launch
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLambdaRolePolicies(t *testing.T) {
+	retries := int64(0)
+	conf := &Config{
+		Name:   "app",
+		Region: "us-east-1",
+		Async: Async{
+			MaxRetries: &retries,
+			OnFailure:  "arn:aws:sqs:us-east-1:123456789012:failed",
+			DLQ:        "arn:aws:sns:us-east-1:123456789012:dead",
+		},
+		VPC: VPC{Environments: map[string]VPC{"prod": {Subnets: []string{"subnet-1"}}}},
+		IAM: IAM{Statements: []Statement{{Action: []string{"s3:GetObject"}, Resource: []string{"arn:aws:s3:::uploads/*"}}}},
+	}
+
+	policies, err := lambdaRolePolicies(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for name, document := range policies {
+		names = append(names, name)
+		var v interface{}
+		if err := json.Unmarshal([]byte(document), &v); err != nil {
+			t.Errorf("policy '%v' is not valid JSON: %v\n%v", name, err, document)
+		}
+	}
+	sort.Strings(names)
+
+	want := []string{"app-async-destinations", "app-custom-policy", "app-log-access", "app-stage-variables", "app-vpc-access"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got policies %v, want %v", names, want)
+	}
+
+	custom := policies["app-custom-policy"]
+	if !strings.Contains(custom, `"Effect":"Allow"`) {
+		t.Errorf("expected statements to be allowed by default, got %v", custom)
+	}
+
+	destinations := policies["app-async-destinations"]
+	if !strings.Contains(destinations, "sqs:SendMessage") || !strings.Contains(destinations, "sns:Publish") {
+		t.Errorf("expected the destination policy to allow sending to SQS and SNS, got %v", destinations)
+	}
+}
+
+func TestSamePolicy(t *testing.T) {
+	a := `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "sqs:SendMessage"}]}`
+	b := `{
+		"Statement": [{"Action": "sqs:SendMessage", "Effect": "Allow"}],
+		"Version": "2012-10-17"
+	}`
+
+	if !samePolicy(a, b) {
+		t.Error("expected documents that only differ in formatting to be the same")
+	}
+	if samePolicy(a, strings.Replace(b, "Allow", "Deny", 1)) {
+		t.Error("expected documents with different effects to differ")
+	}
+	if samePolicy(a, "not json") {
+		t.Error("expected an invalid document to differ")
+	}
+}
+
+func TestManagedPolicyTag(t *testing.T) {
+	a := managedPolicyTag("arn:aws:iam::aws:policy/AmazonS3ReadOnlyAccess")
+	b := managedPolicyTag("arn:aws:iam::aws:policy/AmazonSQSReadOnlyAccess")
+
+	if !strings.HasPrefix(a, managedPolicyTagPrefix) || len(a) > 128 {
+		t.Errorf("expected a short tag key starting with %v, got %v", managedPolicyTagPrefix, a)
+	}
+	if a == b {
+		t.Errorf("expected policies to get tags of their own, got %v for both", a)
+	}
+	if a != managedPolicyTag("arn:aws:iam::aws:policy/AmazonS3ReadOnlyAccess") {
+		t.Error("expected the tag of a policy to be stable")
+	}
+}
@@ -0,0 +1,182 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Retry controls how failed AWS calls are retried. Attempts caps the number of tries of a
+// call. Deadline caps the time a Deploy, Plan or Destroy spends waiting on retries, over
+// all of its calls, and the time spent on a single call outside of those. Delays start at
+// BaseDelay and double on every retry, up to MaxDelay.
+type Retry struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Deadline  time.Duration
+}
+
+var defaultRetry = Retry{
+	Attempts:  10,
+	BaseDelay: time.Millisecond * 500,
+	MaxDelay:  time.Second * 10,
+	Deadline:  time.Minute * 2,
+}
+
+// notPropagatedMessages are parts of the errors returned while IAM hasn't made a new role,
+// or a change to its policies, available to other services yet.
+var notPropagatedMessages = []string{
+	"cannot be assumed by Lambda",
+	"execution role does not have permissions",
+	"role ARN does not have required permissions",
+	"Invalid permissions on Lambda function",
+}
+
+// retryBudget is shared by the calls of a Deploy, Plan or Destroy through their context. It
+// holds the time left to wait on retries, and the config whose listener hears about them.
+type retryBudget struct {
+	mu        sync.Mutex
+	remaining time.Duration
+	conf      *Config
+}
+
+type retryBudgetKey struct{}
+
+// withRetryBudget returns a context carrying a retry budget for the calls made with conf.
+func withRetryBudget(ctx context.Context, conf *Config) context.Context {
+	return context.WithValue(ctx, retryBudgetKey{}, &retryBudget{
+		remaining: newRetryer(conf).Deadline,
+		conf:      conf,
+	})
+}
+
+func requestBudget(req *request.Request) *retryBudget {
+	budget, _ := req.Context().Value(retryBudgetKey{}).(*retryBudget)
+	return budget
+}
+
+// retryer is the request.Retryer of every session. It retries throttled calls, server
+// errors and IAM propagation delays, with exponential backoff and jitter.
+type retryer struct {
+	Retry
+	conf *Config
+}
+
+func newRetryer(conf *Config) *retryer {
+	r := conf.Retry
+	if r.Attempts <= 0 {
+		r.Attempts = defaultRetry.Attempts
+	}
+	if r.BaseDelay <= 0 {
+		r.BaseDelay = defaultRetry.BaseDelay
+	}
+	if r.MaxDelay <= 0 {
+		r.MaxDelay = defaultRetry.MaxDelay
+	}
+	if r.Deadline <= 0 {
+		r.Deadline = defaultRetry.Deadline
+	}
+	return &retryer{r, conf}
+}
+
+func (r *retryer) MaxRetries() int {
+	return r.Attempts - 1
+}
+
+func (r *retryer) ShouldRetry(req *request.Request) bool {
+	if r.remaining(req) <= 0 {
+		return false
+	}
+	return req.IsErrorRetryable() || req.IsErrorThrottle() || notPropagated(req.Error)
+}
+
+// remaining returns the time left to wait on retries of req.
+func (r *retryer) remaining(req *request.Request) time.Duration {
+	if budget := requestBudget(req); budget != nil {
+		budget.mu.Lock()
+		defer budget.mu.Unlock()
+		return budget.remaining
+	}
+	return r.Deadline - time.Since(req.Time)
+}
+
+func (r *retryer) RetryRules(req *request.Request) time.Duration {
+	delay := r.BaseDelay << uint(req.RetryCount)
+	if delay > r.MaxDelay || delay <= 0 {
+		delay = r.MaxDelay
+	}
+
+	// Half of the delay is fixed and the other half random, so calls that were throttled
+	// together don't retry together.
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	if remaining := r.remaining(req); delay > remaining {
+		delay = remaining
+	}
+
+	conf := r.conf
+	if budget := requestBudget(req); budget != nil {
+		budget.mu.Lock()
+		budget.remaining -= delay
+		budget.mu.Unlock()
+		conf = budget.conf
+	}
+
+	info(conf, "Waiting on %v (%v), retrying in %v...", requestResource(req), errorCode(req.Error), delay.Round(time.Millisecond*100))
+	return delay
+}
+
+// notPropagated tells whether err is caused by a role, or a policy of it, that IAM hasn't
+// made available to the service yet.
+func notPropagated(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, message := range notPropagatedMessages {
+		if strings.Contains(err.Error(), message) {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceFields are the input fields that name the resource of a call, in order of
+// preference.
+var resourceFields = []string{
+	"FunctionName", "RoleName", "RestApiId", "Bucket", "QueueUrl", "TopicArn",
+	"Rule", "EventSourceArn", "ResourceArn", "Resource", "Name",
+}
+
+// requestResource describes a request by its operation and the resource it acts on, e.g.
+// "CreateFunction of 'app'".
+func requestResource(req *request.Request) string {
+	params := reflect.Indirect(reflect.ValueOf(req.Params))
+	if params.Kind() == reflect.Struct {
+		for _, name := range resourceFields {
+			field := params.FieldByName(name)
+			if !field.IsValid() {
+				continue
+			}
+			if value, ok := field.Interface().(*string); ok && aws.StringValue(value) != "" {
+				return fmt.Sprintf("%v of '%v'", req.Operation.Name, *value)
+			}
+		}
+	}
+	return req.Operation.Name
+}
+
+func errorCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return "error"
+}
@@ -0,0 +1,96 @@
+package launch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func failedRequest(ctx context.Context, err error) *request.Request {
+	req := &request.Request{
+		Operation:   &request.Operation{Name: "GetFunction"},
+		Params:      &lambda.GetFunctionInput{FunctionName: aws.String("app")},
+		Time:        time.Now(),
+		Error:       err,
+		HTTPRequest: httptest.NewRequest(http.MethodPost, "/", nil),
+	}
+	req.SetContext(ctx)
+	return req
+}
+
+func TestRetryerShouldRetry(t *testing.T) {
+	r := newRetryer(&Config{})
+
+	tests := []struct {
+		err   error
+		retry bool
+	}{
+		{awserr.New("ThrottlingException", "Rate exceeded", nil), true},
+		{awserr.New("InvalidParameterValueException", "The role defined for the function cannot be assumed by Lambda.", nil), true},
+		{awserr.New("ResourceNotFoundException", "Function not found", nil), false},
+		{awserr.New("ValidationException", "1 validation error", nil), false},
+	}
+
+	for _, tt := range tests {
+		if got := r.ShouldRetry(failedRequest(context.Background(), tt.err)); got != tt.retry {
+			t.Errorf("expected retrying %v to be %v, got %v", tt.err, tt.retry, got)
+		}
+	}
+}
+
+func TestRetryBudgetIsShared(t *testing.T) {
+	var waits int
+	conf := &Config{
+		Retry: Retry{BaseDelay: 40 * time.Millisecond, MaxDelay: 40 * time.Millisecond, Deadline: 100 * time.Millisecond},
+		Listener: ListenerFunc(func(n Notice) {
+			if _, isInfo := n.(Info); isInfo {
+				waits++
+			}
+		}),
+	}
+	r := newRetryer(conf)
+	ctx := withRetryBudget(context.Background(), conf)
+	throttled := awserr.New("ThrottlingException", "Rate exceeded", nil)
+
+	// Every call starts with a fresh request time, so only the shared budget ends the retries.
+	var total time.Duration
+	calls := 0
+	for ; r.ShouldRetry(failedRequest(ctx, throttled)); calls++ {
+		if calls > 10 {
+			t.Fatal("expected the budget to run out")
+		}
+		total += r.RetryRules(failedRequest(ctx, throttled))
+	}
+
+	if total != 100*time.Millisecond {
+		t.Errorf("expected the calls to wait 100ms in total, got %v", total)
+	}
+	if waits != calls {
+		t.Errorf("expected the listener to hear about %v retries, got %v", calls, waits)
+	}
+
+	if !r.ShouldRetry(failedRequest(withRetryBudget(context.Background(), conf), throttled)) {
+		t.Error("expected another run to have a budget of its own")
+	}
+}
+
+func TestRetryerWithoutBudget(t *testing.T) {
+	r := newRetryer(&Config{Retry: Retry{Deadline: time.Minute}})
+	req := failedRequest(context.Background(), awserr.New("ThrottlingException", "Rate exceeded", nil))
+
+	if !r.ShouldRetry(req) {
+		t.Error("expected a new call to be retried")
+	}
+
+	req.Time = time.Now().Add(-2 * time.Minute)
+	if r.ShouldRetry(req) {
+		t.Error("expected a call past its deadline not to be retried")
+	}
+}
@@ -0,0 +1,297 @@
+package launch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// RouteConfig returns the config used to deploy the function behind a route. It shares
+// everything with the app config, except for the name, description, port, sources and
+// async settings. Canary releases only apply to the app function, and only events with a
+// path below the route are kept.
+func RouteConfig(route Route, conf *Config) *Config {
+	c := *conf
+	c.Name = routeFunctionName(route, conf)
+	c.Port = route.Port
+	c.Source = route.Source
+	c.Server = route.Server
+	c.Routes = nil
+	c.Canary.Weight = 0
+	c.Tags = resourceTags(conf)
+	c.Tags["launch:route"] = route.Name
+	c.Events = nil
+	// Hooks run once per deployment, with the app function.
+	c.Hooks = Hooks{}
+
+	for _, event := range conf.Events {
+		if r, isRoute := pathRoute(event.Path, conf); isRoute && r.Name == route.Name {
+			c.Events = append(c.Events, event)
+		}
+	}
+
+	if c.Source == "" {
+		c.Source = route.Name
+	}
+
+	if route.Description != "" {
+		c.Description = route.Description
+	}
+
+	if route.Async != nil {
+		c.Async = *route.Async
+	}
+
+	return &c
+}
+
+// CreateOrUpdateRouteFunctions deploys the function of every route, and returns them
+// keyed by route name.
+func CreateOrUpdateRouteFunctions(conf *Config) (map[string]*lambda.FunctionConfiguration, error) {
+	fns := map[string]*lambda.FunctionConfiguration{}
+
+	for _, route := range conf.Routes {
+		routeConf := RouteConfig(route, conf)
+
+		if err := CheckServerFile(routeConf); err != nil {
+			return nil, err
+		}
+
+		fn, err := CreateOrUpdateFunction(routeConf)
+		if err != nil {
+			return nil, fmt.Errorf("error deploying route '%v': %w", route.Path, err)
+		}
+
+		fns[route.Name] = fn
+	}
+
+	return fns, nil
+}
+
+// getOrCreateRoute wires the route prefix, and everything below it, to the function of
+// the route.
+func getOrCreateRoute(
+	client *ag.APIGateway,
+	api *ag.RestApi,
+	route Route,
+	fn *lambda.FunctionConfiguration,
+	authorizer *ag.Authorizer,
+	role *iam.Role,
+	conf *Config) error {
+
+	prefix, err := getOrCreateResourcePath(client, api, routePrefix(route), conf)
+	if err != nil {
+		return err
+	}
+
+	proxy, err := getOrCreateResourcePath(client, api, routePrefix(route)+"/"+proxyPath, conf)
+	if err != nil {
+		return err
+	}
+
+	for _, resource := range []*ag.Resource{prefix, proxy} {
+		if _, err = getOrCreateMethod(client, api, resource, methodAccess(*resource.Path, authorizer, conf), conf); err != nil {
+			return err
+		}
+
+		if _, err = getOrCreateIntegration(client, api, resource, fn, role, RouteConfig(route, conf)); err != nil {
+			return err
+		}
+
+		if err = getOrCreateCORS(client, api, resource, conf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeStaleRoutes deletes the resources of routes that are no longer in the config. Routes
+// are recognized by their proxy resources, which are integrated with a route function of
+// the app. Only what launch created for a route is deleted, see removeRoute.
+func removeStaleRoutes(client *ag.APIGateway, api *ag.RestApi, conf *Config) error {
+	resources, err := getResources(client, api)
+	if err != nil {
+		return err
+	}
+
+	// The prefix of each route, keyed by the name of its function.
+	current := map[string]string{}
+	for _, route := range conf.Routes {
+		current[routeFunctionName(route, conf)] = routePrefix(route)
+	}
+
+	for _, res := range resources {
+		prefix := strings.TrimSuffix(*res.Path, "/"+proxyPath)
+		if prefix == *res.Path || prefix == "" {
+			continue
+		}
+
+		integ, err := getIntegration(api, res, client)
+		if err != nil {
+			return err
+		}
+
+		if integ == nil {
+			continue
+		}
+
+		name, isRoute, err := routeFunction(aws.StringValue(integ.Uri), conf)
+		if err != nil {
+			return err
+		}
+
+		if !isRoute || current[name] == prefix {
+			continue
+		}
+
+		deleted(conf, "route", prefix)
+		if err = removeRoute(client, api, res, aws.StringValue(integ.Uri), conf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// routeFunction returns the name of the function an integration URI invokes, and whether
+// it is a route function of the app, as told by its launch:app and launch:route tags.
+func routeFunction(uri string, conf *Config) (string, bool, error) {
+	const suffix = ":${stageVariables.environment}/invocations"
+
+	i := strings.Index(uri, "/functions/")
+	if i < 0 || !strings.HasSuffix(uri, suffix) {
+		return "", false, nil
+	}
+
+	arn := strings.TrimSuffix(uri[i+len("/functions/"):], suffix)
+	parts := strings.Split(arn, ":")
+	if len(parts) != 7 || !strings.HasPrefix(parts[6], conf.Name+"-") || uri != rewriteLambdaARN(arn, conf) {
+		return "", false, nil
+	}
+
+	out, err := lambda.New(conf.Session).ListTags(&lambda.ListTagsInput{Resource: aws.String(arn)})
+	if isNotFound(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	tags := aws.StringValueMap(out.Tags)
+	return parts[6], tags["launch:app"] == resourceTags(conf)["launch:app"] && tags["launch:route"] != "", nil
+}
+
+// removeRoute deletes the proxy resource of a route, along with the methods getOrCreateRoute
+// added to its prefix. The prefix and its parents are deleted as well once they are left
+// without methods and children.
+func removeRoute(client *ag.APIGateway, api *ag.RestApi, proxy *ag.Resource, uri string, conf *Config) error {
+	_, err := client.DeleteResource(&ag.DeleteResourceInput{
+		RestApiId:  api.Id,
+		ResourceId: proxy.Id,
+	})
+	if err != nil {
+		return err
+	}
+
+	prefix, err := client.GetResource(&ag.GetResourceInput{
+		RestApiId:  api.Id,
+		ResourceId: proxy.ParentId,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, httpMethod := range []string{"ANY", "OPTIONS"} {
+		method, err := getMethod(client, api, prefix, httpMethod)
+		if err != nil {
+			return err
+		}
+
+		if method == nil {
+			continue
+		}
+
+		integ := method.MethodIntegration
+		if httpMethod == "ANY" && (integ == nil || aws.StringValue(integ.Uri) != uri) {
+			continue
+		}
+		if httpMethod == "OPTIONS" && !isCORSMethod(method) {
+			continue
+		}
+
+		_, err = client.DeleteMethod(&ag.DeleteMethodInput{
+			RestApiId:  api.Id,
+			ResourceId: prefix.Id,
+			HttpMethod: aws.String(httpMethod),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	resources, err := getResources(client, api)
+	if err != nil {
+		return err
+	}
+
+	byID := map[string]*ag.Resource{}
+	children := map[string]int{}
+	for _, res := range resources {
+		byID[*res.Id] = res
+		children[aws.StringValue(res.ParentId)]++
+	}
+
+	for res := byID[*prefix.Id]; res != nil && res.ParentId != nil && children[*res.Id] == 0; res = byID[*res.ParentId] {
+		methods, err := client.GetResource(&ag.GetResourceInput{
+			RestApiId:  api.Id,
+			ResourceId: res.Id,
+			Embed:      aws.StringSlice([]string{"methods"}),
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(methods.ResourceMethods) > 0 {
+			break
+		}
+
+		_, err = client.DeleteResource(&ag.DeleteResourceInput{
+			RestApiId:  api.Id,
+			ResourceId: res.Id,
+		})
+		if err != nil {
+			return err
+		}
+		children[*res.ParentId]--
+	}
+
+	return nil
+}
+
+// routePrefix returns the path of a route without the trailing proxy segment, e.g. '/api'
+// for both '/api' and '/api/{proxy+}'.
+func routePrefix(route Route) string {
+	path := strings.TrimSuffix(strings.Trim(route.Path, "/"), proxyPath)
+	return "/" + strings.Trim(path, "/")
+}
+
+// pathRoute returns the route serving a request path, if any.
+func pathRoute(path string, conf *Config) (Route, bool) {
+	path = strings.SplitN(path, "?", 2)[0]
+	for _, route := range conf.Routes {
+		prefix := routePrefix(route)
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+func routeFunctionName(route Route, conf *Config) string {
+	return conf.Name + "-" + route.Name
+}
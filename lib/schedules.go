@@ -0,0 +1,221 @@
+package launch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	cwe "github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// scheduleHeader tells the app which schedule a request was sent by.
+const scheduleHeader = "X-Launch-Schedule"
+
+// proxyEvent is the subset of an API Gateway proxy event the shim relies on.
+type proxyEvent struct {
+	Resource              string            `json:"resource"`
+	Path                  string            `json:"path"`
+	HTTPMethod            string            `json:"httpMethod"`
+	Headers               map[string]string `json:"headers,omitempty"`
+	QueryStringParameters map[string]string `json:"queryStringParameters,omitempty"`
+	Body                  string            `json:"body,omitempty"`
+	StageVariables        map[string]string `json:"stageVariables,omitempty"`
+}
+
+// CreateOrUpdateSchedules creates a rule for every schedule of the current environment,
+// sending a request to the function serving the path of the schedule. Rules of schedules
+// that are no longer configured are removed.
+func CreateOrUpdateSchedules(fn *lambda.FunctionConfiguration, routes map[string]*lambda.FunctionConfiguration, conf *Config) error {
+	client := cwe.New(conf.Session)
+
+	for _, schedule := range conf.Schedules {
+		target, targetConf := scheduleTarget(schedule, fn, routes, conf)
+
+		updated(conf, "schedule", schedule.Name, schedule.Schedule)
+		rule, err := client.PutRule(&cwe.PutRuleInput{
+			Name:               aws.String(scheduleRuleName(schedule.Name, conf)),
+			ScheduleExpression: aws.String(schedule.Schedule),
+			Description:        aws.String(fmt.Sprintf("%v %v", scheduleMethod(schedule), schedule.Path)),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to create schedule '%v': %w", schedule.Name, err)
+		}
+
+		if err = addEventPermission(rule.RuleArn, scheduleStatementID(schedule.Name, conf), targetConf); err != nil {
+			return fmt.Errorf("unable to give cloudwatch events access to lambda: %w", err)
+		}
+
+		event, err := scheduleEvent(schedule)
+		if err != nil {
+			return err
+		}
+
+		_, err = client.PutTargets(&cwe.PutTargetsInput{
+			Rule: aws.String(scheduleRuleName(schedule.Name, conf)),
+			Targets: []*cwe.Target{
+				{
+					Id:    aws.String(conf.Environment),
+					Arn:   aws.String(fmt.Sprintf("%v:%v", lambdaRootARN(*target.FunctionArn, targetConf), conf.Environment)),
+					Input: aws.String(event),
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("unable to add lambda as target of schedule '%v': %w", schedule.Name, err)
+		}
+
+		if err = tagRule(client, rule.RuleArn, target, conf); err != nil {
+			return fmt.Errorf("unable to tag schedule '%v': %w", schedule.Name, err)
+		}
+	}
+
+	return removeStaleSchedules(client, conf)
+}
+
+// scheduleTarget returns the function serving the path of a schedule, along with its config.
+func scheduleTarget(
+	schedule Schedule,
+	fn *lambda.FunctionConfiguration,
+	routes map[string]*lambda.FunctionConfiguration,
+	conf *Config) (*lambda.FunctionConfiguration, *Config) {
+
+	if route, isRoute := pathRoute(schedule.Path, conf); isRoute {
+		return routes[route.Name], RouteConfig(route, conf)
+	}
+
+	return fn, conf
+}
+
+// scheduleEvent returns the proxy event sent by a schedule. It carries no stage variables,
+// so that the shim reads the current ones from the stage.
+func scheduleEvent(schedule Schedule) (string, error) {
+	event := proxyEvent{
+		Resource:   "/{proxy+}",
+		HTTPMethod: scheduleMethod(schedule),
+		Headers:    map[string]string{scheduleHeader: schedule.Name},
+		Body:       schedule.Body,
+	}
+
+	var err error
+	event.Path, event.QueryStringParameters, err = splitQuery(schedule.Path)
+	if err != nil {
+		return "", fmt.Errorf("invalid query in path of schedule '%v': %w", schedule.Name, err)
+	}
+
+	if schedule.Body != "" {
+		event.Headers["Content-Type"] = "application/json"
+	}
+
+	b, err := json.Marshal(event)
+	return string(b), err
+}
+
+// splitQuery splits the query off a path, keeping the first value of each parameter like
+// API Gateway does in queryStringParameters.
+func splitQuery(path string) (string, map[string]string, error) {
+	parts := strings.SplitN(path, "?", 2)
+	if len(parts) == 1 {
+		return path, nil, nil
+	}
+
+	query, err := url.ParseQuery(parts[1])
+	if err != nil {
+		return "", nil, err
+	}
+
+	params := map[string]string{}
+	for k := range query {
+		params[k] = query.Get(k)
+	}
+	return parts[0], params, nil
+}
+
+func removeStaleSchedules(client *cwe.CloudWatchEvents, conf *Config) error {
+	stale, err := staleSchedules(client, conf)
+	if err != nil {
+		return err
+	}
+
+	lambdaClient := lambda.New(conf.Session)
+	for _, rule := range stale {
+		name := strings.TrimPrefix(rule, scheduleRuleName("", conf))
+		deleted(conf, "schedule", name)
+
+		_, err := client.RemoveTargets(&cwe.RemoveTargetsInput{
+			Rule: aws.String(rule),
+			Ids:  aws.StringSlice([]string{conf.Environment}),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to remove target of schedule '%v': %w", name, err)
+		}
+
+		if _, err = client.DeleteRule(&cwe.DeleteRuleInput{Name: aws.String(rule)}); err != nil {
+			return fmt.Errorf("unable to remove schedule '%v': %w", name, err)
+		}
+
+		// The schedule may have targeted any of the functions, and the permission is gone
+		// from all but one of them.
+		functions := []string{conf.Name}
+		for _, route := range conf.Routes {
+			functions = append(functions, routeFunctionName(route, conf))
+		}
+		for _, function := range functions {
+			lambdaClient.RemovePermission(&lambda.RemovePermissionInput{
+				FunctionName: aws.String(function),
+				StatementId:  aws.String(scheduleStatementID(name, conf)),
+				Qualifier:    aws.String(conf.Environment),
+			})
+		}
+	}
+
+	return nil
+}
+
+// staleSchedules returns the rules of the current environment whose schedule is no longer
+// configured.
+func staleSchedules(client *cwe.CloudWatchEvents, conf *Config) ([]string, error) {
+	current := map[string]bool{}
+	for _, schedule := range conf.Schedules {
+		current[scheduleRuleName(schedule.Name, conf)] = true
+	}
+
+	var stale []string
+	input := &cwe.ListRulesInput{
+		NamePrefix: aws.String(scheduleRuleName("", conf)),
+	}
+	for {
+		page, err := client.ListRules(input)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list schedules: %w", err)
+		}
+
+		for _, rule := range page.Rules {
+			if !current[*rule.Name] {
+				stale = append(stale, *rule.Name)
+			}
+		}
+
+		if page.NextToken == nil {
+			return stale, nil
+		}
+		input.NextToken = page.NextToken
+	}
+}
+
+func scheduleMethod(schedule Schedule) string {
+	if schedule.Method != "" {
+		return strings.ToUpper(schedule.Method)
+	}
+	return "GET"
+}
+
+func scheduleRuleName(name string, conf *Config) string {
+	return fmt.Sprintf("%v-%v-schedule-%v", conf.Name, conf.Environment, name)
+}
+
+func scheduleStatementID(name string, conf *Config) string {
+	return fmt.Sprintf("%v-schedule-%v", conf.Environment, name)
+}
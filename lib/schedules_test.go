@@ -0,0 +1,80 @@
+package launch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestScheduleEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		want     map[string]interface{}
+	}{
+		{
+			name:     "get",
+			schedule: Schedule{Name: "cleanup", Path: "/jobs/cleanup"},
+			want: map[string]interface{}{
+				"resource":   "/{proxy+}",
+				"path":       "/jobs/cleanup",
+				"httpMethod": "GET",
+				"headers":    map[string]interface{}{scheduleHeader: "cleanup"},
+			},
+		},
+		{
+			name:     "post with a query and a body",
+			schedule: Schedule{Name: "report", Method: "post", Path: "/jobs/report?period=day&period=week&format=csv", Body: `{"to": "ops"}`},
+			want: map[string]interface{}{
+				"resource":              "/{proxy+}",
+				"path":                  "/jobs/report",
+				"httpMethod":            "POST",
+				"headers":               map[string]interface{}{scheduleHeader: "report", "Content-Type": "application/json"},
+				"queryStringParameters": map[string]interface{}{"period": "day", "format": "csv"},
+				"body":                  `{"to": "ops"}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := scheduleEvent(tt.schedule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(event), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleEventRejectsInvalidQuery(t *testing.T) {
+	if _, err := scheduleEvent(Schedule{Name: "broken", Path: "/jobs?a=%zz"}); err == nil {
+		t.Error("expected an invalid query to be rejected")
+	}
+}
+
+func TestValidateScheduleRuleName(t *testing.T) {
+	conf := &Config{
+		Name:        "app",
+		Region:      "us-east-1",
+		Port:        8000,
+		Environment: "production",
+		Schedules:   []Schedule{{Name: "a-schedule-with-a-name-long-enough-to-break-the-limit", Schedule: "rate(1 hour)", Path: "/"}},
+	}
+
+	if errs := ValidateConfig(conf); len(errs) != 1 {
+		t.Errorf("expected the rule name '%v' to be too long, got %v", scheduleRuleName(conf.Schedules[0].Name, conf), errs)
+	}
+
+	conf.Schedules[0].Name = "cleanup"
+	if errs := ValidateConfig(conf); len(errs) != 0 {
+		t.Errorf("expected the rule name '%v' to be valid, got %v", scheduleRuleName(conf.Schedules[0].Name, conf), errs)
+	}
+}
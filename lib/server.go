@@ -3,29 +3,32 @@ package launch
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
-func CheckServerFile() error {
-	file, err := os.Open("./server")
+func CheckServerFile(conf *Config) error {
+	path := filepath.Join(sourceDir(conf), serverFile(conf))
+	file, err := os.Open(path)
 
 	if err != nil {
-		return fmt.Errorf("Can't find or open your 'server' file: %v\n", err)
+		return fmt.Errorf("Can't find or open your '%v' file: %v\n", path, err)
 	}
 
-	info, err := file.Stat()
+	stat, err := file.Stat()
 
 	if err != nil {
-		return fmt.Errorf("Can't stat your 'server' file: %v\n", err)
+		return fmt.Errorf("Can't stat your '%v' file: %v\n", path, err)
 	}
 
-	if (info.Mode() & 0001) == 0 {
-		if err := file.Chmod(info.Mode() | 0111); err != nil {
+	if (stat.Mode() & 0001) == 0 {
+		if err := file.Chmod(stat.Mode() | 0111); err != nil {
 			return fmt.Errorf(
-				"Your 'server' file is not executable. An error occured while trying to update permissions: %v\n",
+				"Your '%v' file is not executable. An error occured while trying to update permissions: %v\n",
+				path,
 				err,
 			)
 		}
-		fmt.Println("Making the 'server' file executable")
+		info(conf, "Making the '%v' file executable", path)
 	}
 
 	return nil
@@ -0,0 +1,105 @@
+package launch
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// NewSession creates a session from the credentials chain, using the profile of the config
+// and the shared config files. When a role is configured, it is assumed on top of those
+// credentials, prompting for an MFA token if the role needs one.
+func NewSession(conf *Config) (*session.Session, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:                  *request.WithRetryer(&aws.Config{Region: aws.String(conf.Region)}, newRetryer(conf)),
+		Profile:                 conf.Profile,
+		SharedConfigState:       session.SharedConfigEnable,
+		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS credentials: %w", err)
+	}
+	sess.Handlers.AfterRetry.PushBackNamed(classifyErrors)
+
+	if conf.AssumeRole == "" {
+		return sess, nil
+	}
+
+	creds := stscreds.NewCredentials(sess, conf.AssumeRole, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = roleSessionName(conf)
+		if conf.ExternalID != "" {
+			p.ExternalID = aws.String(conf.ExternalID)
+		}
+		if conf.MFASerial != "" {
+			p.SerialNumber = aws.String(conf.MFASerial)
+			p.TokenProvider = stscreds.StdinTokenProvider
+		}
+	})
+
+	return sess.Copy(&aws.Config{Credentials: creds}), nil
+}
+
+// ApplyEnvironment overlays the account, region and credentials declared for the current
+// environment on the config.
+func ApplyEnvironment(conf *Config) {
+	settings, defined := conf.Envs[conf.Environment]
+	if !defined {
+		return
+	}
+
+	overlay := []struct {
+		value  string
+		target *string
+	}{
+		{settings.Account, &conf.Account},
+		{settings.Region, &conf.Region},
+		{settings.Profile, &conf.Profile},
+		{settings.AssumeRole, &conf.AssumeRole},
+		{settings.ExternalID, &conf.ExternalID},
+		{settings.MFASerial, &conf.MFASerial},
+	}
+
+	for _, o := range overlay {
+		if o.value != "" {
+			*o.target = o.value
+		}
+	}
+}
+
+// CheckAccount looks up the identity of the session, and refuses to go on when it belongs
+// to another account than the one declared for the environment.
+func CheckAccount(conf *Config) (*sts.GetCallerIdentityOutput, error) {
+	identity, err := CallerIdentity(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Account != "" && aws.StringValue(identity.Account) != conf.Account {
+		return nil, &AccountError{Environment: conf.Environment, Expected: conf.Account, Actual: aws.StringValue(identity.Account)}
+	}
+
+	return identity, nil
+}
+
+// CallerIdentity returns the account and ARN the session acts as.
+func CallerIdentity(conf *Config) (*sts.GetCallerIdentityOutput, error) {
+	identity, err := sts.New(conf.Session).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up caller identity: %w", err)
+	}
+	return identity, nil
+}
+
+// roleSessionName names the assumed role session after the app, so deployments can be told
+// apart in CloudTrail. Session names are limited to 64 characters.
+func roleSessionName(conf *Config) string {
+	name := fmt.Sprintf("launch-%v-%v", conf.Name, conf.Environment)
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
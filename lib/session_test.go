@@ -0,0 +1,43 @@
+package launch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyEnvironment(t *testing.T) {
+	conf := &Config{
+		Environment: "prod",
+		Region:      "us-east-1",
+		Profile:     "default",
+		Envs: map[string]Environment{
+			"prod": {Account: "123456789012", Region: "eu-west-1", AssumeRole: "arn:aws:iam::123456789012:role/deploy"},
+		},
+	}
+
+	ApplyEnvironment(conf)
+
+	if conf.Account != "123456789012" || conf.Region != "eu-west-1" || conf.AssumeRole != "arn:aws:iam::123456789012:role/deploy" {
+		t.Errorf("expected the prod settings to be applied, got %+v", conf)
+	}
+	if conf.Profile != "default" {
+		t.Errorf("expected the profile to be kept, got %v", conf.Profile)
+	}
+
+	conf = &Config{Environment: "dev", Region: "us-east-1", Envs: conf.Envs}
+	ApplyEnvironment(conf)
+	if conf.Region != "us-east-1" || conf.Account != "" {
+		t.Errorf("expected dev to be left as is, got %+v", conf)
+	}
+}
+
+func TestRoleSessionName(t *testing.T) {
+	if got := roleSessionName(&Config{Name: "app", Environment: "dev"}); got != "launch-app-dev" {
+		t.Errorf("got %v, want launch-app-dev", got)
+	}
+
+	got := roleSessionName(&Config{Name: strings.Repeat("a", 80), Environment: "dev"})
+	if len(got) != 64 {
+		t.Errorf("expected the session name to be cut to 64 characters, got %v", len(got))
+	}
+}
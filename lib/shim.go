@@ -2,6 +2,7 @@ package launch
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"text/template"
 )
@@ -10,29 +11,81 @@ var shimTmpl = `
 var http = require('http');
 var spawn = require('child_process').spawn;
 var qs = require('querystring');
+var AWS = require('aws-sdk');
 
 var waiting = false;
 var running = false;
 
+var events = {{json .Events}} || [];
+
 exports.proxy = proxy;
 
 function proxy(event, context) {
-	boot(event);
-	if (!running) {
+	if (running) {
+		if (event.httpMethod) {
+			sendRequest(event, context);
+		} else {
+			sendEvents(event, context);
+		}
+	} else if (waiting) {
 		console.log("Proxy: Waiting for application to start.");
 		setTimeout(function() {
 			proxy(event, context);
 		}, 1);
 	} else {
-		sendRequest(event, context);
+		waiting = true;
+		stageVariables(event, context, function(err, vars) {
+			if (err) {
+				waiting = false;
+				console.error('Proxy: Unable to read stage variables: ' + err.message);
+				return context.fail(err);
+			}
+			boot(vars);
+			proxy(event, context);
+		});
+	}
+}
+
+// Only requests through the API carry stage variables. Schedules, warmers, queues, topics,
+// buckets and event buses boot the app with the variables of the stage named after the
+// alias the function was invoked through, read when the app boots so edits are picked up.
+function stageVariables(event, context, callback) {
+	if (event.stageVariables) {
+		return callback(null, event.stageVariables);
+	}
+
+	var environment = context.invokedFunctionArn.split(':')[7];
+	if (!environment) {
+		return callback(null, {});
 	}
+
+	var client = new AWS.APIGateway();
+	client.getRestApis({limit: 500}, function(err, apis) {
+		if (err) {
+			return callback(err);
+		}
+
+		var api = apis.items.filter(function(api) {
+			return api.name === {{json apiName}};
+		})[0];
+		if (!api) {
+			return callback(new Error('API ' + {{json apiName}} + ' does not exist'));
+		}
+
+		client.getStage({restApiId: api.id, stageName: environment}, function(err, stage) {
+			if (err) {
+				return callback(err);
+			}
+			callback(null, stage.variables || {environment: environment});
+		});
+	});
 }
 
 function sendRequest(event, context) {
 	var queries = event.queryStringParameters ? '?' + qs.stringify(event.queryStringParameters) : '';
 	var options = {
 		port: {{.Port}},
-		method: event.method,
+		method: event.httpMethod,
 		path: event.path + queries,
 		headers: event.headers
 	};
@@ -54,6 +107,9 @@ function sendRequest(event, context) {
 				delete res.headers['transfer-encoding'];
 				res.headers['content-length'] = buf.byteLength;
 			}
+{{- if .CORS.Inject}}
+			addCORSHeaders(event.headers || {}, res.headers);
+{{- end}}
 			context.succeed({
 				statusCode: res.statusCode,
 				headers: res.headers,
@@ -62,44 +118,181 @@ function sendRequest(event, context) {
 		});
 	});
 
+	// Binary media types, such as the ones static files add to the API, reach the proxy
+	// base64 encoded.
 	if (event.body) {
-		req.setHeader('Content-Length', event.body.length);
-		req.write(event.body);
+		var body = Buffer.from(event.body, event.isBase64Encoded ? 'base64' : 'utf8');
+		req.setHeader('Content-Length', body.length);
+		req.write(body);
 	}
 	req.end();
 }
 
-function boot(event) {
-	if (!running && !waiting) {
-		waiting = true;
-		var server = spawn('./server', [], {env: event.stageVariables});
+function sendEvents(event, context) {
+	var records = event.Records || [event];
+	var failures = [];
+	var pending = records.length;
 
-		server.stdout.on('data', function(data) {
-			running = true;
-			console.log(String(data));
-		});
+	if (pending === 0) {
+		return context.succeed();
+	}
+
+	records.forEach(function(record) {
+		var target = eventTarget(record);
+		if (!target) {
+			console.error('Proxy: No event configured for ' + JSON.stringify(record));
+			failures.push(record);
+			return done();
+		}
 
-		server.stderr.on('data', function(data) {
-			running = true;
-			console.error(String(data));
+		postEvent(target, record.launchEvent ? record.event : record, function(ok) {
+			if (!ok) {
+				failures.push(record);
+			}
+			done();
 		});
+	});
+
+	function done() {
+		if (--pending > 0) {
+			return;
+		}
+
+		if (records[0].eventSource === 'aws:sqs') {
+			context.succeed({
+				batchItemFailures: failures.map(function(record) {
+					return {itemIdentifier: record.messageId};
+				})
+			});
+		} else if (failures.length > 0) {
+			context.fail(new Error(failures.length + ' of ' + records.length + ' events failed'));
+		} else {
+			context.succeed();
+		}
+	}
+}
 
-		server.on('close', function(code) {
-			running = false;
-			waiting = false;
-			console.error('Server exited with code ' + code);
+function eventTarget(record) {
+	for (var i = 0; i < events.length; i++) {
+		var e = events[i];
+		var sources = [];
+		for (var env in e.Source) {
+			sources.push(e.Source[env]);
+		}
+
+		if (e.Type === 'eventbridge' && record.launchEvent === e.Name) {
+			return e;
+		}
+		if (e.Type === 'sqs' && record.eventSource === 'aws:sqs' && sources.indexOf(record.eventSourceARN) !== -1) {
+			return e;
+		}
+		if (e.Type === 'sns' && record.EventSource === 'aws:sns' && sources.indexOf(record.Sns.TopicArn) !== -1) {
+			return e;
+		}
+		if (e.Type === 's3' && record.eventSource === 'aws:s3' && sources.indexOf(record.s3.bucket.name) !== -1) {
+			var key = decodeURIComponent(record.s3.object.key.replace(/\+/g, ' '));
+			if (key.indexOf(e.Prefix) === 0 && key.slice(key.length - e.Suffix.length) === e.Suffix) {
+				return e;
+			}
+		}
+	}
+}
+
+// postEvent delivers a record to the app. Anything but a 2xx response is a failure.
+function postEvent(target, body, callback) {
+	var payload = JSON.stringify(body);
+	var req = http.request({
+		port: {{.Port}},
+		method: 'POST',
+		path: target.Path,
+		headers: {
+			'Content-Type': 'application/json',
+			'Content-Length': Buffer.byteLength(payload),
+			'X-Launch-Event': target.Name
+		}
+	}, function(res) {
+		res.resume();
+		res.on('end', function() {
+			var ok = res.statusCode >= 200 && res.statusCode < 300;
+			if (!ok) {
+				console.error('Proxy: Event ' + target.Name + ' failed with status ' + res.statusCode);
+			}
+			callback(ok);
 		});
+	});
+
+	req.on('error', function(err) {
+		console.error('Proxy: Event ' + target.Name + ' failed: ' + err.message);
+		callback(false);
+	});
+	req.end(payload);
+}
+
+{{if .CORS.Inject -}}
+var cors = {{json .CORS}};
+
+function addCORSHeaders(requestHeaders, headers) {
+	if (headers['access-control-allow-origin']) {
+		return;
 	}
+
+	var origin = requestHeaders['Origin'] || requestHeaders['origin'];
+	if (cors.Origins.indexOf('*') !== -1) {
+		headers['access-control-allow-origin'] = '*';
+	} else if (origin && cors.Origins.indexOf(origin) !== -1) {
+		headers['access-control-allow-origin'] = origin;
+		headers['vary'] = headers['vary'] ? headers['vary'] + ', Origin' : 'Origin';
+	} else {
+		return;
+	}
+
+	if (cors.Credentials) {
+		headers['access-control-allow-credentials'] = 'true';
+	}
+}
+
+{{end -}}
+function boot(env) {
+	var server = spawn('./{{.Server}}', [], {env: env});
+
+	server.stdout.on('data', function(data) {
+		running = true;
+		console.log(String(data));
+	});
+
+	server.stderr.on('data', function(data) {
+		running = true;
+		console.error(String(data));
+	});
+
+	server.on('close', function(code) {
+		running = false;
+		waiting = false;
+		console.error('Server exited with code ' + code);
+	});
 }`
 
 func Shim(conf *Config) ([]byte, error) {
 	buf := new(bytes.Buffer)
-	tmpl, err := template.New("shim").Parse(shimTmpl)
+	tmpl, err := template.New("shim").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		// Routes share the API of the app.
+		"apiName": func() string {
+			return resourceTags(conf)["launch:app"] + "-api"
+		},
+	}).Parse(shimTmpl)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to parse shim template: %v", err)
 	}
 
-	if err := tmpl.Execute(buf, conf); err != nil {
+	data := *conf
+	data.Server = serverFile(conf)
+	data.Events = functionEvents(conf)
+
+	if err := tmpl.Execute(buf, &data); err != nil {
 		return nil, fmt.Errorf("Unable to generate shim: %v", err)
 	}
 
@@ -0,0 +1,103 @@
+package launch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// runShim renders the shim for an app listening on server, and sends it a single proxy
+// event with node. The aws-sdk module is stubbed, since requests carry stage variables.
+func runShim(t *testing.T, server *httptest.Server, event map[string]interface{}) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &Config{Name: "app", Environment: "dev"}
+	conf.Port, _ = strconv.Atoi(port)
+
+	shim, err := Shim(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "index.js", string(shim))
+	writeFile(t, dir, "node_modules/aws-sdk/index.js", "module.exports = {};")
+	writeFile(t, dir, "run.js", `require('./index').proxy(`+string(payload)+`, {
+	succeed: function() { process.exit(0); },
+	fail: function(err) { console.error(err); process.exit(1); }
+});`)
+	writeFile(t, dir, "server", "#!/bin/sh\necho started\nexec sleep 10\n")
+	if err := exec.Command("chmod", "+x", filepath.Join(dir, "server")).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(node, "run.js")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("shim failed: %v\n%s", err, out)
+	}
+}
+
+func TestShimDecodesBinaryBodies(t *testing.T) {
+	body := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0xfe}
+
+	tests := []struct {
+		name  string
+		event map[string]interface{}
+		want  []byte
+	}{
+		{"base64", map[string]interface{}{
+			"body":            base64.StdEncoding.EncodeToString(body),
+			"isBase64Encoded": true,
+		}, body},
+		{"text", map[string]interface{}{
+			"body":            "héllo",
+			"isBase64Encoded": false,
+		}, []byte("héllo")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []byte
+			var length int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received, _ = ioutil.ReadAll(r.Body)
+				length = r.ContentLength
+			}))
+			t.Cleanup(server.Close)
+
+			tt.event["httpMethod"] = "POST"
+			tt.event["path"] = "/upload"
+			tt.event["headers"] = map[string]string{"Content-Type": "image/png"}
+			tt.event["stageVariables"] = map[string]string{"environment": "dev"}
+			runShim(t, server, tt.event)
+
+			if string(received) != string(tt.want) {
+				t.Errorf("expected the app to receive %q, got %q", tt.want, received)
+			}
+			if length != int64(len(tt.want)) {
+				t.Errorf("expected a Content-Length of %v, got %v", len(tt.want), length)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package launch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// SmokeTest is a request sent to the stage after a deployment. The deployment is rolled
+// back unless the response has Status, which defaults to 200, contains the Contains text,
+// has the values of JSON, and arrives within MaxLatency.
+type SmokeTest struct {
+	Name       string
+	Method     string
+	Path       string
+	Headers    map[string]string
+	Body       string
+	Status     int
+	Contains   string
+	JSON       []JSONCheck
+	MaxLatency string `yaml:"max-latency,omitempty" mapstructure:"max-latency"`
+}
+
+// JSONCheck compares the value at Path, a dotted path like 'data.items.0.id', in a JSON
+// response to Equals.
+type JSONCheck struct {
+	Path   string
+	Equals string
+}
+
+// SmokeTestError is returned when smoke tests fail. Failures describes each failed test.
+type SmokeTestError struct {
+	Failures []string
+}
+
+func (e *SmokeTestError) Error() string {
+	return fmt.Sprintf("%v smoke test(s) failed: %v", len(e.Failures), strings.Join(e.Failures, "; "))
+}
+
+// smokeTimeout limits tests without a MaxLatency.
+const smokeTimeout = time.Second * 30
+
+// smokeClock measures the latency of smoke tests.
+var smokeClock = time.Now
+
+// RunSmokeTests sends the smoke tests of the config to baseURL, which is the invoke URL of
+// the stage after a deployment, but can be any server. Every test is run, and the failed
+// ones are returned as a SmokeTestError.
+func RunSmokeTests(ctx context.Context, baseURL string, conf *Config) error {
+	return runSmokeTests(ctx, baseURL, nil, conf)
+}
+
+// runSmokeTests runs the smoke tests through transport, or over HTTP when it is nil.
+func runSmokeTests(ctx context.Context, baseURL string, transport http.RoundTripper, conf *Config) error {
+	var failures []string
+	for _, test := range conf.SmokeTests {
+		latency, err := runSmokeTest(ctx, baseURL, transport, test)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			warn(conf, "smoke test '%v' failed: %v", smokeTestName(test), err)
+			failures = append(failures, fmt.Sprintf("'%v' %v", smokeTestName(test), err))
+			continue
+		}
+		info(conf, "Smoke test '%v' passed in %v", smokeTestName(test), latency.Round(time.Millisecond))
+	}
+
+	if len(failures) > 0 {
+		return &SmokeTestError{Failures: failures}
+	}
+	return nil
+}
+
+func runSmokeTest(ctx context.Context, baseURL string, transport http.RoundTripper, test SmokeTest) (time.Duration, error) {
+	timeout := smokeTimeout
+	maxLatency, _ := time.ParseDuration(test.MaxLatency)
+	if maxLatency > 0 {
+		// Give slow responses some room, so they fail on their latency instead of a timeout.
+		timeout = maxLatency * 2
+	}
+
+	req, err := http.NewRequestWithContext(ctx, smokeTestMethod(test), strings.TrimRight(baseURL, "/")+test.Path, strings.NewReader(test.Body))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range test.Headers {
+		req.Header.Set(k, v)
+	}
+
+	start := smokeClock()
+	res, err := (&http.Client{Transport: transport, Timeout: timeout}).Do(req)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() && maxLatency > 0 && ctx.Err() == nil {
+		return smokeClock().Sub(start), fmt.Errorf("took more than %v", timeout)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	latency := smokeClock().Sub(start)
+	if err != nil {
+		return latency, err
+	}
+
+	status := test.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if res.StatusCode != status {
+		return latency, fmt.Errorf("expected status %v, got %v", status, res.StatusCode)
+	}
+
+	if test.Contains != "" && !strings.Contains(string(body), test.Contains) {
+		return latency, fmt.Errorf("expected the body to contain '%v'", test.Contains)
+	}
+
+	if len(test.JSON) > 0 {
+		var doc interface{}
+		if err = json.Unmarshal(body, &doc); err != nil {
+			return latency, fmt.Errorf("expected a JSON body: %w", err)
+		}
+		for _, check := range test.JSON {
+			value, found := jsonPath(doc, check.Path)
+			if !found {
+				return latency, fmt.Errorf("expected a value at '%v'", check.Path)
+			}
+			if value != check.Equals {
+				return latency, fmt.Errorf("expected '%v' at '%v', got '%v'", check.Equals, check.Path, value)
+			}
+		}
+	}
+
+	if maxLatency > 0 && latency > maxLatency {
+		return latency, fmt.Errorf("took %v, more than %v", latency.Round(time.Millisecond), maxLatency)
+	}
+
+	return latency, nil
+}
+
+// versionTransport sends requests straight to a version of the app function as proxy
+// events, so that smoke tests reach a canary whatever share of the traffic it gets. Route
+// functions never have a canary, so their paths go to the alias of the environment.
+type versionTransport struct {
+	version string
+	conf    *Config
+}
+
+func (t versionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	inv := Invocation{
+		Method:  req.Method,
+		Path:    req.URL.RequestURI(),
+		Headers: map[string]string{},
+	}
+	for k := range req.Header {
+		inv.Headers[k] = req.Header.Get(k)
+	}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		inv.Body = string(body)
+	}
+	if _, isRoute := pathRoute(req.URL.Path, t.conf); !isRoute {
+		inv.Version = t.version
+	}
+
+	result, err := Invoke(inv, t.conf)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &http.Response{
+		StatusCode: result.StatusCode,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(result.Body)),
+		Request:    req,
+	}
+	for k, v := range result.Headers {
+		res.Header.Set(k, v)
+	}
+	return res, nil
+}
+
+// jsonPath looks up a dotted path in a decoded JSON document. Numbers index arrays. Strings
+// are returned as they are, other values as JSON.
+func jsonPath(doc interface{}, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, found := node[key]
+			if !found {
+				return "", false
+			}
+			doc = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			doc = node[i]
+		default:
+			return "", false
+		}
+	}
+
+	if s, isString := doc.(string); isString {
+		return s, true
+	}
+	b, _ := json.Marshal(doc)
+	return string(b), true
+}
+
+func smokeTestName(test SmokeTest) string {
+	if test.Name != "" {
+		return test.Name
+	}
+	return smokeTestMethod(test) + " " + test.Path
+}
+
+func smokeTestMethod(test SmokeTest) string {
+	if test.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(test.Method)
+}
+
+// currentAliases returns the aliases of the functions, keyed by function name. Functions
+// without an alias for the environment are left out.
+func currentAliases(confs []*Config) (map[string]*lambda.AliasConfiguration, error) {
+	aliases := map[string]*lambda.AliasConfiguration{}
+	for _, c := range confs {
+		alias, err := getAlias(lambda.New(c.Session), c)
+		if err != nil {
+			return nil, err
+		}
+		if alias != nil {
+			aliases[c.Name] = alias
+		}
+	}
+	return aliases, nil
+}
+
+// rollback moves the aliases back to the versions they pointed at before the deployment,
+// along with the canary routing they had.
+func rollback(previous map[string]*lambda.AliasConfiguration, confs []*Config) error {
+	var errs []error
+	for _, c := range confs {
+		alias, existed := previous[c.Name]
+		if !existed {
+			warn(c, "alias '%v' of '%v' is new, there is no version to roll back to", c.Environment, c.Name)
+			continue
+		}
+
+		routing := &lambda.AliasRoutingConfiguration{AdditionalVersionWeights: map[string]*float64{}}
+		if alias.RoutingConfig != nil && alias.RoutingConfig.AdditionalVersionWeights != nil {
+			routing = alias.RoutingConfig
+		}
+
+		updated(c, "alias", c.Environment, "rolled back to version "+*alias.FunctionVersion)
+		_, err := lambda.New(c.Session).UpdateAlias(&lambda.UpdateAliasInput{
+			Name:            aws.String(c.Environment),
+			FunctionName:    aws.String(c.Name),
+			FunctionVersion: alias.FunctionVersion,
+			RoutingConfig:   routing,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to roll back '%v': %w", c.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
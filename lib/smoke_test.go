@@ -0,0 +1,201 @@
+package launch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func smokeServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	mux.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"items": [{"name": "smoke", "count": 2}]}`)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// clockTransport answers every request with 'ok', and moves the clock of the smoke tests
+// forward by delay instead of waiting. Requests slower than timeout fail like a client
+// timeout would.
+type clockTransport struct {
+	now     time.Time
+	delay   time.Duration
+	timeout time.Duration
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func (c *clockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.now = c.now.Add(c.delay)
+	if c.timeout > 0 && c.delay > c.timeout {
+		return nil, timeoutError{}
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("ok")),
+		Request:    req,
+	}, nil
+}
+
+func TestRunSmokeTestsPasses(t *testing.T) {
+	server := smokeServer(t)
+	conf := &Config{SmokeTests: []SmokeTest{
+		{Path: "/health", Contains: "ok", MaxLatency: "1s"},
+		{
+			Path:     "/items",
+			Method:   "post",
+			Headers:  map[string]string{"Content-Type": "application/json"},
+			Status:   http.StatusCreated,
+			Contains: "smoke",
+			JSON: []JSONCheck{
+				{Path: "items.0.name", Equals: "smoke"},
+				{Path: "items.0.count", Equals: "2"},
+			},
+		},
+	}}
+
+	if err := RunSmokeTests(context.Background(), server.URL, conf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunSmokeTestsFails(t *testing.T) {
+	server := smokeServer(t)
+
+	tests := []struct {
+		test SmokeTest
+		want string
+	}{
+		{SmokeTest{Path: "/missing"}, "expected status 200, got 404"},
+		{SmokeTest{Path: "/health", Contains: "healthy"}, "expected the body to contain 'healthy'"},
+		{SmokeTest{Path: "/health", JSON: []JSONCheck{{Path: "status", Equals: "ok"}}}, "expected a JSON body"},
+		{
+			SmokeTest{
+				Path:    "/items",
+				Method:  "POST",
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Status:  http.StatusCreated,
+				JSON:    []JSONCheck{{Path: "items.1.name", Equals: "smoke"}},
+			},
+			"expected a value at 'items.1.name'",
+		},
+		{
+			SmokeTest{
+				Path:    "/items",
+				Method:  "POST",
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Status:  http.StatusCreated,
+				JSON:    []JSONCheck{{Path: "items.0.name", Equals: "other"}},
+			},
+			"expected 'other' at 'items.0.name', got 'smoke'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(smokeTestName(tt.test), func(t *testing.T) {
+			err := RunSmokeTests(context.Background(), server.URL, &Config{SmokeTests: []SmokeTest{tt.test}})
+
+			var failed *SmokeTestError
+			if !errors.As(err, &failed) || len(failed.Failures) != 1 {
+				t.Fatalf("expected a single failure, got %v", err)
+			}
+			if !strings.Contains(failed.Failures[0], tt.want) {
+				t.Errorf("expected the failure to mention %q, got %q", tt.want, failed.Failures[0])
+			}
+		})
+	}
+}
+
+func TestRunSmokeTestsLatency(t *testing.T) {
+	t.Cleanup(func() { smokeClock = time.Now })
+
+	// A max latency of 100ms gives the client a timeout of 200ms.
+	tests := []struct {
+		delay time.Duration
+		want  string
+	}{
+		{50 * time.Millisecond, ""},
+		{150 * time.Millisecond, "took 150ms, more than 100ms"},
+		{250 * time.Millisecond, "took more than 200ms"},
+	}
+
+	for _, tt := range tests {
+		transport := &clockTransport{now: time.Now(), delay: tt.delay, timeout: 200 * time.Millisecond}
+		smokeClock = func() time.Time { return transport.now }
+
+		conf := &Config{SmokeTests: []SmokeTest{{Path: "/", MaxLatency: "100ms"}}}
+		err := runSmokeTests(context.Background(), "http://app", transport, conf)
+
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("expected a response after %v to pass, got %v", tt.delay, err)
+			}
+			continue
+		}
+
+		var failed *SmokeTestError
+		if !errors.As(err, &failed) || !strings.Contains(failed.Failures[0], tt.want) {
+			t.Errorf("expected a response after %v to fail with %q, got %v", tt.delay, tt.want, err)
+		}
+	}
+}
+
+func TestRollbackRestoresCanaryRouting(t *testing.T) {
+	alias := &fakeAlias{version: "3", weights: map[string]float64{}}
+	conf := canaryConfig(t, alias)
+
+	previous := map[string]*lambda.AliasConfiguration{
+		conf.Name: {
+			FunctionVersion: aws.String("1"),
+			RoutingConfig: &lambda.AliasRoutingConfiguration{
+				AdditionalVersionWeights: map[string]*float64{"2": aws.Float64(0.1)},
+			},
+		},
+	}
+
+	if err := rollback(previous, []*Config{conf}); err != nil {
+		t.Fatal(err)
+	}
+
+	if alias.version != "1" || alias.weights["2"] != 0.1 || len(alias.weights) != 1 {
+		t.Errorf("expected version 1 with 10%% on version 2, got %v with %v", alias.version, alias.weights)
+	}
+}
+
+func TestRunSmokeTestsRunsEveryTest(t *testing.T) {
+	server := smokeServer(t)
+	conf := &Config{SmokeTests: []SmokeTest{
+		{Path: "/missing"},
+		{Path: "/health"},
+		{Path: "/gone"},
+	}}
+
+	var failed *SmokeTestError
+	if err := RunSmokeTests(context.Background(), server.URL, conf); !errors.As(err, &failed) || len(failed.Failures) != 2 {
+		t.Fatalf("expected 2 failures, got %v", err)
+	}
+}
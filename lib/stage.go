@@ -0,0 +1,211 @@
+package launch
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+// allMethods is the key of method settings that apply to every method in the stage.
+var allMethods = "*/*"
+
+// updateStage applies the stage settings of the current environment. Settings that have
+// been removed from the config are reset.
+func updateStage(client *ag.APIGateway, api *ag.RestApi, conf *Config) error {
+	stage, err := client.GetStage(&ag.GetStageInput{
+		RestApiId: api.Id,
+		StageName: aws.String(conf.Environment),
+	})
+	if err != nil {
+		return err
+	}
+
+	account, err := client.GetAccount(&ag.GetAccountInput{})
+	if err != nil {
+		return err
+	}
+
+	level := strings.ToUpper(conf.Stage[conf.Environment].Logging)
+	if accessLogEnabled(conf) || (level != "" && level != "OFF") {
+		if err = ensureCloudWatchRole(client, account, conf); err != nil {
+			return fmt.Errorf("unable to give API Gateway access to CloudWatch: %w", err)
+		}
+	}
+
+	var destination string
+	if accessLogEnabled(conf) {
+		if destination, err = getOrCreateAccessLogGroup(conf); err != nil {
+			return fmt.Errorf("unable to create access log group: %w", err)
+		}
+	}
+
+	patches := stagePatches(stage, account, destination, conf)
+	if len(patches) == 0 {
+		return nil
+	}
+
+	updated(conf, "stage", conf.Environment, "settings")
+	_, err = client.UpdateStage(&ag.UpdateStageInput{
+		RestApiId:       api.Id,
+		StageName:       aws.String(conf.Environment),
+		PatchOperations: patches,
+	})
+	return err
+}
+
+// stagePatches lists the changes needed to bring the stage in line with the config. The
+// destination is the ARN of the access log group, if access logging is enabled.
+func stagePatches(stage *ag.Stage, account *ag.Account, destination string, conf *Config) []*ag.PatchOperation {
+	var patches []*ag.PatchOperation
+	replace := func(path, value string) {
+		patches = append(patches, &ag.PatchOperation{
+			Op:    aws.String(ag.OpReplace),
+			Path:  aws.String(path),
+			Value: aws.String(value),
+		})
+	}
+
+	settings := conf.Stage[conf.Environment]
+
+	// Method settings can't be unset one by one, so any difference resets all of them
+	// before the current ones are applied.
+	desired := methodSettings(settings)
+	current, defined := stage.MethodSettings[allMethods]
+	if defined && !reflect.DeepEqual(effectiveSettings(currentMethodSettings(current), account), effectiveSettings(desired, account)) {
+		patches = append(patches, &ag.PatchOperation{
+			Op:   aws.String(ag.OpRemove),
+			Path: aws.String("/" + allMethods),
+		})
+		defined = false
+	}
+
+	if !defined {
+		var keys []string
+		for key := range desired {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			replace(fmt.Sprintf("/%v/%v", allMethods, key), desired[key])
+		}
+	}
+
+	cached := settings.Cache.Size != ""
+	if aws.BoolValue(stage.CacheClusterEnabled) != cached {
+		replace("/cacheClusterEnabled", strconv.FormatBool(cached))
+	}
+	if cached && aws.StringValue(stage.CacheClusterSize) != settings.Cache.Size {
+		replace("/cacheClusterSize", settings.Cache.Size)
+	}
+
+	if aws.BoolValue(stage.TracingEnabled) != settings.Tracing {
+		replace("/tracingEnabled", strconv.FormatBool(settings.Tracing))
+	}
+
+	logs := stage.AccessLogSettings
+	if destination != "" {
+		if logs == nil || aws.StringValue(logs.DestinationArn) != destination {
+			replace("/accessLogSettings/destinationArn", destination)
+		}
+		if logs == nil || aws.StringValue(logs.Format) != accessLogFormat(conf) {
+			replace("/accessLogSettings/format", accessLogFormat(conf))
+		}
+	} else if logs != nil && aws.StringValue(logs.DestinationArn) != "" {
+		patches = append(patches, &ag.PatchOperation{
+			Op:   aws.String(ag.OpRemove),
+			Path: aws.String("/accessLogSettings"),
+		})
+	}
+
+	return patches
+}
+
+// methodSettings returns the method settings for every method in the stage, keyed by their
+// patch path.
+func methodSettings(settings StageSettings) map[string]string {
+	values := map[string]string{}
+
+	if settings.Throttling.Rate != 0 || settings.Throttling.Burst != 0 {
+		values["throttling/rateLimit"] = strconv.FormatFloat(settings.Throttling.Rate, 'f', -1, 64)
+		values["throttling/burstLimit"] = strconv.FormatInt(settings.Throttling.Burst, 10)
+	}
+
+	if settings.Cache.Size != "" {
+		values["caching/enabled"] = "true"
+		values["caching/ttlInSeconds"] = strconv.FormatInt(settings.Cache.TTL, 10)
+	}
+
+	if level := strings.ToUpper(settings.Logging); level != "" && level != "OFF" {
+		values["logging/loglevel"] = level
+	}
+
+	if settings.DataTrace {
+		values["logging/dataTrace"] = "true"
+	}
+
+	if settings.Metrics {
+		values["metrics/enabled"] = "true"
+	}
+
+	return values
+}
+
+// currentMethodSettings returns the settings of a stage in the same form as methodSettings.
+func currentMethodSettings(setting *ag.MethodSetting) map[string]string {
+	values := map[string]string{}
+
+	rate := aws.Float64Value(setting.ThrottlingRateLimit)
+	burst := aws.Int64Value(setting.ThrottlingBurstLimit)
+	if rate > 0 || burst > 0 {
+		values["throttling/rateLimit"] = strconv.FormatFloat(rate, 'f', -1, 64)
+		values["throttling/burstLimit"] = strconv.FormatInt(burst, 10)
+	}
+
+	if aws.BoolValue(setting.CachingEnabled) {
+		values["caching/enabled"] = "true"
+		values["caching/ttlInSeconds"] = strconv.FormatInt(aws.Int64Value(setting.CacheTtlInSeconds), 10)
+	}
+
+	if level := aws.StringValue(setting.LoggingLevel); level != "" && level != "OFF" {
+		values["logging/loglevel"] = level
+	}
+
+	if aws.BoolValue(setting.DataTraceEnabled) {
+		values["logging/dataTrace"] = "true"
+	}
+
+	if aws.BoolValue(setting.MetricsEnabled) {
+		values["metrics/enabled"] = "true"
+	}
+
+	return values
+}
+
+// effectiveSettings returns method settings as API Gateway applies them. Throttling limits
+// that match the account defaults are left out, since they make no difference and the
+// stage reports them whether they were set or not.
+func effectiveSettings(values map[string]string, account *ag.Account) map[string]string {
+	if account.ThrottleSettings == nil {
+		return values
+	}
+
+	rate := strconv.FormatFloat(aws.Float64Value(account.ThrottleSettings.RateLimit), 'f', -1, 64)
+	burst := strconv.FormatInt(aws.Int64Value(account.ThrottleSettings.BurstLimit), 10)
+	if values["throttling/rateLimit"] != rate || values["throttling/burstLimit"] != burst {
+		return values
+	}
+
+	effective := map[string]string{}
+	for k, v := range values {
+		if !strings.HasPrefix(k, "throttling/") {
+			effective[k] = v
+		}
+	}
+	return effective
+}
@@ -0,0 +1,153 @@
+package launch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+var defaultAccount = &ag.Account{
+	ThrottleSettings: &ag.ThrottleSettings{
+		RateLimit:  aws.Float64(10000),
+		BurstLimit: aws.Int64(5000),
+	},
+}
+
+func TestStagePatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		stage    *ag.Stage
+		settings StageSettings
+		want     []string
+	}{
+		{
+			name:  "unchanged",
+			stage: &ag.Stage{},
+			want:  nil,
+		},
+		{
+			name:     "new settings",
+			stage:    &ag.Stage{},
+			settings: StageSettings{Throttling: Throttling{Rate: 100, Burst: 200}, Metrics: true, Tracing: true},
+			want: []string{
+				"replace /*/*/metrics/enabled",
+				"replace /*/*/throttling/burstLimit",
+				"replace /*/*/throttling/rateLimit",
+				"replace /tracingEnabled",
+			},
+		},
+		{
+			name: "same settings",
+			stage: &ag.Stage{MethodSettings: map[string]*ag.MethodSetting{
+				allMethods: {ThrottlingRateLimit: aws.Float64(100), ThrottlingBurstLimit: aws.Int64(200), MetricsEnabled: aws.Bool(true)},
+			}},
+			settings: StageSettings{Throttling: Throttling{Rate: 100, Burst: 200}, Metrics: true},
+			want:     nil,
+		},
+		{
+			name: "account default throttling",
+			stage: &ag.Stage{MethodSettings: map[string]*ag.MethodSetting{
+				allMethods: {ThrottlingRateLimit: aws.Float64(10000), ThrottlingBurstLimit: aws.Int64(5000), MetricsEnabled: aws.Bool(true)},
+			}},
+			settings: StageSettings{Metrics: true},
+			want:     nil,
+		},
+		{
+			name: "changed settings",
+			stage: &ag.Stage{MethodSettings: map[string]*ag.MethodSetting{
+				allMethods: {ThrottlingRateLimit: aws.Float64(100), ThrottlingBurstLimit: aws.Int64(200), MetricsEnabled: aws.Bool(true)},
+			}},
+			settings: StageSettings{Metrics: true},
+			want: []string{
+				"remove /*/*",
+				"replace /*/*/metrics/enabled",
+			},
+		},
+		{
+			name:     "removed cache and tracing",
+			stage:    &ag.Stage{CacheClusterEnabled: aws.Bool(true), CacheClusterSize: aws.String("0.5"), TracingEnabled: aws.Bool(true)},
+			settings: StageSettings{},
+			want: []string{
+				"replace /cacheClusterEnabled",
+				"replace /tracingEnabled",
+			},
+		},
+		{
+			name:     "resized cache",
+			stage:    &ag.Stage{CacheClusterEnabled: aws.Bool(true), CacheClusterSize: aws.String("0.5")},
+			settings: StageSettings{Cache: Cache{Size: "1.6", TTL: 300}},
+			want: []string{
+				"replace /*/*/caching/enabled",
+				"replace /*/*/caching/ttlInSeconds",
+				"replace /cacheClusterSize",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &Config{Environment: "dev", Stage: map[string]StageSettings{"dev": tt.settings}}
+
+			got := patchPaths(stagePatches(tt.stage, defaultAccount, "", conf))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "account defaults",
+			values: map[string]string{"throttling/rateLimit": "10000", "throttling/burstLimit": "5000", "metrics/enabled": "true"},
+			want:   map[string]string{"metrics/enabled": "true"},
+		},
+		{
+			name:   "custom limits",
+			values: map[string]string{"throttling/rateLimit": "100", "throttling/burstLimit": "5000"},
+			want:   map[string]string{"throttling/rateLimit": "100", "throttling/burstLimit": "5000"},
+		},
+		{
+			name:   "no throttling",
+			values: map[string]string{},
+			want:   map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := effectiveSettings(tt.values, defaultAccount); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStageThrottling(t *testing.T) {
+	tests := []struct {
+		throttling Throttling
+		valid      bool
+	}{
+		{Throttling{}, true},
+		{Throttling{Rate: 100, Burst: 200}, true},
+		{Throttling{Rate: 100}, false},
+		{Throttling{Burst: 200}, false},
+	}
+
+	for _, tt := range tests {
+		conf := &Config{Name: "app", Region: "us-east-1", Port: 8000, Stage: map[string]StageSettings{
+			"dev": {Throttling: tt.throttling},
+		}}
+
+		if errs := ValidateConfig(conf); (len(errs) == 0) != tt.valid {
+			t.Errorf("expected %+v to be valid: %v, got %v", tt.throttling, tt.valid, errs)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package launch
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+var (
+	// staticBucketVariable is the stage variable holding the bucket of the environment.
+	staticBucketVariable = "staticBucket"
+
+	staticMediaTypes = []string{"image/*", "font/*", "application/octet-stream"}
+	staticHeaders    = []string{"Content-Type", "Content-Length", "Cache-Control", "ETag", "Last-Modified"}
+)
+
+func staticEnabled(conf *Config) bool {
+	return conf.Static.Dir != ""
+}
+
+// SyncStaticFiles uploads the static files of the app to the bucket of the current
+// environment, and deletes files that no longer exist locally.
+func SyncStaticFiles(fn *lambda.FunctionConfiguration, conf *Config) error {
+	if !staticEnabled(conf) {
+		return nil
+	}
+
+	client := s3.New(conf.Session)
+	bucket := staticBucketName(fn, conf)
+
+	if err := getOrCreateBucket(client, bucket, conf); err != nil {
+		return fmt.Errorf("unable to create bucket '%v': %w", bucket, err)
+	}
+
+	info(conf, "Syncing '%v' to '%v'", conf.Static.Dir, bucket)
+	return syncDir(client, bucket, conf.Static.Dir, conf.Static.CacheControl, conf)
+}
+
+func getOrCreateBucket(client s3iface.S3API, bucket string, conf *Config) error {
+	_, err := client.HeadBucket(&s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if err == nil {
+		return nil
+	}
+
+	if !isNotFound(err) {
+		return err
+	}
+
+	created(conf, "bucket", bucket, "")
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(bucket),
+	}
+
+	// us-east-1 is the default location, and can't be given as a constraint.
+	if conf.Region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(conf.Region),
+		}
+	}
+
+	_, err = client.CreateBucket(input)
+	return err
+}
+
+// syncDir makes the bucket hold exactly the files in dir. Files are only uploaded when
+// their checksum differs from the ETag of the object.
+func syncDir(client s3iface.S3API, bucket, dir, cacheControl string, conf *Config) error {
+	remote := map[string]string{}
+	err := client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			remote[*obj.Key] = strings.Trim(aws.StringValue(obj.ETag), `"`)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		sum := md5.Sum(body)
+		etag, exists := remote[key]
+		delete(remote, key)
+
+		if exists && etag == hex.EncodeToString(sum[:]) {
+			return nil
+		}
+
+		input := &s3.PutObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(body),
+			ContentType: aws.String(contentType(path)),
+		}
+		if cacheControl != "" {
+			input.CacheControl = aws.String(cacheControl)
+		}
+
+		updated(conf, "static file", key, "")
+		_, err = client.PutObject(input)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var stale []*s3.ObjectIdentifier
+	for key := range remote {
+		deleted(conf, "static file", key)
+		stale = append(stale, &s3.ObjectIdentifier{Key: aws.String(key)})
+	}
+
+	// DeleteObjects takes at most 1000 keys per call.
+	for len(stale) > 0 {
+		batch := stale
+		if len(batch) > 1000 {
+			batch = stale[:1000]
+		}
+		stale = stale[len(batch):]
+
+		_, err = client.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3.Delete{Objects: batch, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func contentType(path string) string {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// getOrCreateStatic serves the objects of the environment bucket below the static prefix,
+// through an S3 integration. Static files are authorized like the rest of the API.
+func getOrCreateStatic(
+	client *ag.APIGateway,
+	api *ag.RestApi,
+	fn *lambda.FunctionConfiguration,
+	authorizer *ag.Authorizer,
+	conf *Config) error {
+
+	role, err := GetOrCreateStaticRole(fn, conf)
+	if err != nil {
+		return fmt.Errorf("error creating role for static files: %w", err)
+	}
+
+	resource, err := getOrCreateResourcePath(client, api, staticPrefix(conf)+"/"+proxyPath, conf)
+	if err != nil {
+		return err
+	}
+
+	acc := methodAccess(*resource.Path, authorizer, conf)
+
+	method, err := getMethod(client, api, resource, "GET")
+	if err != nil {
+		return err
+	}
+
+	if method != nil {
+		integ := method.MethodIntegration
+		if integ != nil && aws.StringValue(integ.Uri) == staticURI(conf) && aws.StringValue(integ.Credentials) == *role.Arn {
+			if patches := methodPatches(method, acc); len(patches) > 0 {
+				updated(conf, "method", "GET "+*resource.Path, "")
+				_, err = client.UpdateMethod(&ag.UpdateMethodInput{
+					RestApiId:       api.Id,
+					ResourceId:      resource.Id,
+					HttpMethod:      aws.String("GET"),
+					PatchOperations: patches,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return addStaticMediaTypes(client, api, conf)
+		}
+
+		deleted(conf, "method", "GET "+*resource.Path)
+		_, err = client.DeleteMethod(&ag.DeleteMethodInput{
+			RestApiId:  api.Id,
+			ResourceId: resource.Id,
+			HttpMethod: aws.String("GET"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	created(conf, "integration", "GET "+*resource.Path, "S3")
+	if err = createStaticMethod(client, api, resource, role, acc, conf); err != nil {
+		return err
+	}
+
+	return addStaticMediaTypes(client, api, conf)
+}
+
+func createStaticMethod(client *ag.APIGateway, api *ag.RestApi, resource *ag.Resource, role *iam.Role, acc access, conf *Config) error {
+	_, err := client.PutMethod(&ag.PutMethodInput{
+		RestApiId:         api.Id,
+		ResourceId:        resource.Id,
+		HttpMethod:        aws.String("GET"),
+		AuthorizationType: aws.String(acc.authorizationType),
+		AuthorizerId:      acc.authorizerID,
+		ApiKeyRequired:    aws.Bool(acc.apiKeyRequired),
+		RequestParameters: map[string]*bool{
+			"method.request.path.proxy": aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.PutIntegration(&ag.PutIntegrationInput{
+		RestApiId:             api.Id,
+		ResourceId:            resource.Id,
+		HttpMethod:            aws.String("GET"),
+		Type:                  aws.String(ag.IntegrationTypeAws),
+		IntegrationHttpMethod: aws.String("GET"),
+		Credentials:           role.Arn,
+		Uri:                   aws.String(staticURI(conf)),
+		PassthroughBehavior:   aws.String("WHEN_NO_MATCH"),
+		RequestParameters: map[string]*string{
+			"integration.request.path.key": aws.String("method.request.path.proxy"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	methodParams := map[string]*bool{}
+	integrationParams := map[string]*string{}
+	for _, header := range staticHeaders {
+		methodParams["method.response.header."+header] = aws.Bool(false)
+		integrationParams["method.response.header."+header] = aws.String("integration.response.header." + header)
+	}
+
+	// S3 answers 403 rather than 404 for missing objects when the caller can't list the bucket.
+	responses := []struct {
+		status, pattern string
+		params          map[string]*string
+	}{
+		{"200", "", integrationParams},
+		{"404", `4\d{2}`, nil},
+	}
+
+	for _, response := range responses {
+		input := &ag.PutMethodResponseInput{
+			RestApiId:  api.Id,
+			ResourceId: resource.Id,
+			HttpMethod: aws.String("GET"),
+			StatusCode: aws.String(response.status),
+		}
+		if response.params != nil {
+			input.ResponseParameters = methodParams
+		}
+
+		if _, err = client.PutMethodResponse(input); err != nil {
+			return err
+		}
+
+		_, err = client.PutIntegrationResponse(&ag.PutIntegrationResponseInput{
+			RestApiId:          api.Id,
+			ResourceId:         resource.Id,
+			HttpMethod:         aws.String("GET"),
+			StatusCode:         aws.String(response.status),
+			SelectionPattern:   aws.String(response.pattern),
+			ResponseParameters: response.params,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addStaticMediaTypes makes API Gateway pass images and fonts through as binary data.
+func addStaticMediaTypes(client *ag.APIGateway, api *ag.RestApi, conf *Config) error {
+	var patches []*ag.PatchOperation
+	for _, mediaType := range staticMediaTypes {
+		if !contains(aws.StringValueSlice(api.BinaryMediaTypes), mediaType) {
+			patches = append(patches, &ag.PatchOperation{
+				Op:   aws.String(ag.OpAdd),
+				Path: aws.String("/binaryMediaTypes/" + strings.Replace(mediaType, "/", "~1", -1)),
+			})
+		}
+	}
+
+	if len(patches) == 0 {
+		return nil
+	}
+
+	updated(conf, "API", *api.Name, "binary media types")
+	_, err := client.UpdateRestApi(&ag.UpdateRestApiInput{
+		RestApiId:       api.Id,
+		PatchOperations: patches,
+	})
+	return err
+}
+
+// staticURI returns the S3 integration URI. The bucket is taken from a stage variable, so
+// each stage serves the bucket of its environment.
+func staticURI(conf *Config) string {
+	return fmt.Sprintf("arn:aws:apigateway:%v:s3:path/${stageVariables.%v}/{key}", conf.Region, staticBucketVariable)
+}
+
+func staticPrefix(conf *Config) string {
+	return "/" + strings.Trim(conf.Static.Prefix, "/")
+}
+
+// staticBucketName includes the account ID, since bucket names are global.
+func staticBucketName(fn *lambda.FunctionConfiguration, conf *Config) string {
+	return strings.ToLower(fmt.Sprintf("%v-%v-static-%v", conf.Name, conf.Environment, accountID(*fn.FunctionArn)))
+}
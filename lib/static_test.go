@@ -0,0 +1,108 @@
+package launch
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// fakeS3 keeps the objects of a single bucket in memory, keyed by their ETag.
+type fakeS3 struct {
+	s3iface.S3API
+	objects  map[string]string
+	uploaded []string
+	deleted  []string
+}
+
+func (f *fakeS3) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	page := &s3.ListObjectsV2Output{}
+	for key, etag := range f.objects {
+		page.Contents = append(page.Contents, &s3.Object{Key: aws.String(key), ETag: aws.String(`"` + etag + `"`)})
+	}
+	fn(page, true)
+	return nil
+}
+
+func (f *fakeS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	body, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[*input.Key] = etag(body)
+	f.uploaded = append(f.uploaded, *input.Key)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	for _, obj := range input.Delete.Objects {
+		delete(f.objects, *obj.Key)
+		f.deleted = append(f.deleted, *obj.Key)
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func etag(body []byte) string {
+	sum := md5.Sum(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "index.html", "<h1>hello</h1>")
+	writeFile(t, dir, "css/app.css", "body {}")
+	writeFile(t, dir, "js/app.js", "new")
+
+	client := &fakeS3{objects: map[string]string{
+		"index.html": etag([]byte("<h1>hello</h1>")),
+		"js/app.js":  etag([]byte("old")),
+		"old.png":    etag([]byte("png")),
+	}}
+
+	if err := syncDir(client, "bucket", dir, "", &Config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(client.uploaded)
+	if want := []string{"css/app.css", "js/app.js"}; !reflect.DeepEqual(client.uploaded, want) {
+		t.Errorf("expected new and changed files to be uploaded, got %v, want %v", client.uploaded, want)
+	}
+
+	if want := []string{"old.png"}; !reflect.DeepEqual(client.deleted, want) {
+		t.Errorf("expected stale objects to be deleted, got %v, want %v", client.deleted, want)
+	}
+
+	if len(client.objects) != 3 {
+		t.Errorf("expected the bucket to hold the 3 local files, got %v", client.objects)
+	}
+}
+
+func TestStaticDirExcluded(t *testing.T) {
+	conf := &Config{Source: "app", Static: Static{Dir: "app/public", Prefix: "/assets"}}
+	if !excludedPaths(conf)["public"] {
+		t.Errorf("expected the static dir to be left out of the package, got %v", excludedPaths(conf))
+	}
+
+	conf.Static.Dir = "public"
+	if len(excludedPaths(conf)) != 0 {
+		t.Errorf("expected a static dir outside the source to be ignored, got %v", excludedPaths(conf))
+	}
+}
@@ -0,0 +1,247 @@
+package launch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+	cwe "github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// resourceTags returns the tags of resources shared by every environment, like the
+// functions, the API and the roles.
+func resourceTags(conf *Config) map[string]string {
+	tags := map[string]string{}
+	for k, v := range conf.Tags {
+		tags[k] = v
+	}
+
+	if _, defined := tags["launch:app"]; !defined {
+		tags["launch:app"] = conf.Name
+	}
+
+	return tags
+}
+
+// environmentTags returns the tags of resources belonging to the current environment, like
+// the stage and the rules. They include the overrides of the environment, and the function
+// version that was deployed to it.
+func environmentTags(fn *lambda.FunctionConfiguration, conf *Config) map[string]string {
+	tags := resourceTags(conf)
+	for k, v := range conf.EnvTags[conf.Environment] {
+		tags[k] = v
+	}
+
+	tags["launch:environment"] = conf.Environment
+	if fn != nil {
+		tags["launch:version"] = aws.StringValue(fn.Version)
+	}
+
+	return tags
+}
+
+// managedTagsKey is the tag listing the keys of the config tags launch set on a resource,
+// so that tags added by other tools are left alone when they aren't in the config.
+const managedTagsKey = "launch:managed-tags"
+
+// managedTags returns the value of the managedTagsKey tag for the given tags.
+func managedTags(tags map[string]string) string {
+	var keys []string
+	for k := range tags {
+		if !strings.HasPrefix(k, "launch:") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, " ")
+}
+
+// tagDiff returns the tags to add or change, and the keys to remove, to get from the
+// current tags to the desired ones. Only keys launch set before are removed: the launch:
+// ones, and those listed in the managedTagsKey tag.
+func tagDiff(current, desired map[string]string) (map[string]string, []string) {
+	desired = copyTags(desired)
+	desired[managedTagsKey] = managedTags(desired)
+
+	changed := map[string]string{}
+	for k, v := range desired {
+		if value, exists := current[k]; !exists || value != v {
+			changed[k] = v
+		}
+	}
+
+	managed := map[string]bool{}
+	for _, k := range strings.Fields(current[managedTagsKey]) {
+		managed[k] = true
+	}
+
+	var removed []string
+	for k := range current {
+		if _, keep := desired[k]; !keep && (managed[k] || strings.HasPrefix(k, "launch:")) {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(removed)
+
+	return changed, removed
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
+
+func tagFunction(client *lambda.Lambda, fn *lambda.FunctionConfiguration, conf *Config) error {
+	arn := aws.String(unqualifiedARN(fn))
+
+	current, err := client.ListTags(&lambda.ListTagsInput{Resource: arn})
+	if err != nil {
+		return err
+	}
+
+	changed, removed := tagDiff(aws.StringValueMap(current.Tags), resourceTags(conf))
+
+	if len(changed) > 0 {
+		updated(conf, "function", conf.Name, "tags")
+		_, err = client.TagResource(&lambda.TagResourceInput{Resource: arn, Tags: aws.StringMap(changed)})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(removed) > 0 {
+		updated(conf, "function", conf.Name, "removed tags "+strings.Join(removed, ", "))
+		_, err = client.UntagResource(&lambda.UntagResourceInput{Resource: arn, TagKeys: aws.StringSlice(removed)})
+	}
+
+	return err
+}
+
+// tagAPIResource tags an API or a stage, given its ARN.
+func tagAPIResource(client *ag.APIGateway, arn string, desired map[string]string, conf *Config) error {
+	current, err := client.GetTags(&ag.GetTagsInput{ResourceArn: aws.String(arn)})
+	if err != nil {
+		return err
+	}
+
+	changed, removed := tagDiff(aws.StringValueMap(current.Tags), desired)
+
+	if len(changed) > 0 {
+		updated(conf, "API", arn, "tags")
+		_, err = client.TagResource(&ag.TagResourceInput{ResourceArn: aws.String(arn), Tags: aws.StringMap(changed)})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(removed) > 0 {
+		updated(conf, "API", arn, "removed tags "+strings.Join(removed, ", "))
+		_, err = client.UntagResource(&ag.UntagResourceInput{ResourceArn: aws.String(arn), TagKeys: aws.StringSlice(removed)})
+	}
+
+	return err
+}
+
+func tagRole(client *iam.IAM, role string, conf *Config) error {
+	current, err := roleTags(client, role)
+	if err != nil {
+		return err
+	}
+
+	// The managed policy tags are kept up to date by syncManagedPolicies.
+	desired := resourceTags(conf)
+	for k, v := range current {
+		if strings.HasPrefix(k, managedPolicyTagPrefix) {
+			desired[k] = v
+		}
+	}
+
+	changed, removed := tagDiff(current, desired)
+
+	if len(changed) > 0 {
+		updated(conf, "role", role, "tags")
+		_, err = client.TagRole(&iam.TagRoleInput{RoleName: aws.String(role), Tags: iamTags(changed)})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(removed) > 0 {
+		updated(conf, "role", role, "removed tags "+strings.Join(removed, ", "))
+		_, err = client.UntagRole(&iam.UntagRoleInput{RoleName: aws.String(role), TagKeys: aws.StringSlice(removed)})
+	}
+
+	return err
+}
+
+func roleTags(client *iam.IAM, role string) (map[string]string, error) {
+	tags := map[string]string{}
+	err := client.ListRoleTagsPages(&iam.ListRoleTagsInput{
+		RoleName: aws.String(role),
+	}, func(page *iam.ListRoleTagsOutput, last bool) bool {
+		for _, tag := range page.Tags {
+			tags[*tag.Key] = *tag.Value
+		}
+		return true
+	})
+
+	return tags, err
+}
+
+func tagRule(client *cwe.CloudWatchEvents, arn *string, fn *lambda.FunctionConfiguration, conf *Config) error {
+	current, err := client.ListTagsForResource(&cwe.ListTagsForResourceInput{ResourceARN: arn})
+	if err != nil {
+		return err
+	}
+
+	tags := map[string]string{}
+	for _, tag := range current.Tags {
+		tags[*tag.Key] = *tag.Value
+	}
+
+	changed, removed := tagDiff(tags, environmentTags(fn, conf))
+
+	if len(changed) > 0 {
+		var eventTags []*cwe.Tag
+		for k, v := range changed {
+			eventTags = append(eventTags, &cwe.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+
+		updated(conf, "rule", *arn, "tags")
+		_, err = client.TagResource(&cwe.TagResourceInput{ResourceARN: arn, Tags: eventTags})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(removed) > 0 {
+		updated(conf, "rule", *arn, "removed tags "+strings.Join(removed, ", "))
+		_, err = client.UntagResource(&cwe.UntagResourceInput{ResourceARN: arn, TagKeys: aws.StringSlice(removed)})
+	}
+
+	return err
+}
+
+func iamTags(tags map[string]string) []*iam.Tag {
+	var list []*iam.Tag
+	for k, v := range tags {
+		list = append(list, &iam.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return list
+}
+
+func apiARN(api *ag.RestApi, conf *Config) string {
+	return fmt.Sprintf("arn:aws:apigateway:%v::/restapis/%v", conf.Region, *api.Id)
+}
+
+func stageARN(api *ag.RestApi, conf *Config) string {
+	return fmt.Sprintf("%v/stages/%v", apiARN(api, conf), conf.Environment)
+}
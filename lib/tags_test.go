@@ -0,0 +1,85 @@
+package launch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		current map[string]string
+		desired map[string]string
+		changed map[string]string
+		removed []string
+	}{
+		{
+			name:    "new resource",
+			current: map[string]string{},
+			desired: map[string]string{"team": "web", "launch:app": "app"},
+			changed: map[string]string{"team": "web", "launch:app": "app", managedTagsKey: "team"},
+		},
+		{
+			name:    "unchanged",
+			current: map[string]string{"team": "web", "launch:app": "app", managedTagsKey: "team"},
+			desired: map[string]string{"team": "web", "launch:app": "app"},
+			changed: map[string]string{},
+		},
+		{
+			name:    "changed value",
+			current: map[string]string{"team": "web", managedTagsKey: "team"},
+			desired: map[string]string{"team": "api"},
+			changed: map[string]string{"team": "api"},
+		},
+		{
+			name:    "removed from the config",
+			current: map[string]string{"team": "web", "cost": "1", "launch:version": "3", managedTagsKey: "cost team"},
+			desired: map[string]string{"team": "web"},
+			changed: map[string]string{managedTagsKey: "team"},
+			removed: []string{"cost", "launch:version"},
+		},
+		{
+			name:    "set by another tool",
+			current: map[string]string{"team": "web", "owner": "ops", managedTagsKey: "team"},
+			desired: map[string]string{"team": "web"},
+			changed: map[string]string{},
+		},
+		{
+			name:    "set before launch managed tags",
+			current: map[string]string{"team": "web", "owner": "ops"},
+			desired: map[string]string{},
+			changed: map[string]string{managedTagsKey: ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, removed := tagDiff(tt.current, tt.desired)
+			if !reflect.DeepEqual(changed, tt.changed) {
+				t.Errorf("changed: got %v, want %v", changed, tt.changed)
+			}
+			if !reflect.DeepEqual(removed, tt.removed) {
+				t.Errorf("removed: got %v, want %v", removed, tt.removed)
+			}
+		})
+	}
+}
+
+func TestEnvironmentTags(t *testing.T) {
+	conf := &Config{
+		Name:        "app",
+		Environment: "prod",
+		Tags:        map[string]string{"team": "web", "tier": "standard"},
+		EnvTags:     map[string]map[string]string{"prod": {"tier": "critical"}},
+	}
+
+	want := map[string]string{
+		"team":               "web",
+		"tier":               "critical",
+		"launch:app":         "app",
+		"launch:environment": "prod",
+	}
+	if got := environmentTags(nil, conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
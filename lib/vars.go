@@ -0,0 +1,183 @@
+package launch
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ag "github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// reservedVariables are set by launch on every stage, and can't be changed.
+var reservedVariables = []string{"environment", staticBucketVariable}
+
+// reservedVariable ignores case, since variables read from launch.yml are lowercased.
+func reservedVariable(name string) bool {
+	for _, reserved := range reservedVariables {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+// variableNamePattern only allows lowercase names, since the names of variables read from
+// launch.yml are lowercased, and deployments and syncs would otherwise treat a variable
+// set with another case as a different one.
+var variableNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// StageVariables returns the variables of the stage of the current environment.
+func StageVariables(conf *Config) (map[string]string, error) {
+	_, stage, err := getStage(ag.New(conf.Session), conf)
+	if err != nil {
+		return nil, err
+	}
+	return aws.StringValueMap(stage.Variables), nil
+}
+
+// SetStageVariable sets a variable on the stage of the current environment, without
+// deploying. Deployments set the variables of the config again.
+func SetStageVariable(name, value string, conf *Config) error {
+	if err := checkVariableName(name); err != nil {
+		return err
+	}
+
+	updated(conf, "stage variable", name, "")
+	return patchStage([]*ag.PatchOperation{variablePatch(ag.OpReplace, name, value)}, conf)
+}
+
+// UnsetStageVariable removes a variable from the stage of the current environment.
+func UnsetStageVariable(name string, conf *Config) error {
+	if err := checkVariableName(name); err != nil {
+		return err
+	}
+
+	vars, err := StageVariables(conf)
+	if err != nil {
+		return err
+	}
+	if _, exists := vars[name]; !exists {
+		return &NotFoundError{Err: fmt.Errorf("stage '%v' has no variable '%v'", conf.Environment, name)}
+	}
+
+	deleted(conf, "stage variable", name)
+	return patchStage([]*ag.PatchOperation{variablePatch(ag.OpRemove, name, "")}, conf)
+}
+
+// SyncStageVariables makes the variables of the stage match those of the config, removing
+// variables that aren't in it. It returns the changes made.
+func SyncStageVariables(conf *Config) ([]Change, error) {
+	fn, err := lambda.New(conf.Session).GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(conf.Name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up function '%v': %w", conf.Name, err)
+	}
+
+	current, err := StageVariables(conf)
+	if err != nil {
+		return nil, err
+	}
+	desired := aws.StringValueMap(stageVariables(fn, conf))
+
+	var changes []Change
+	var patches []*ag.PatchOperation
+	for _, name := range sortedKeys(desired) {
+		value, exists := current[name]
+		if exists && value == desired[name] {
+			continue
+		}
+
+		action := "create"
+		if exists {
+			action = "update"
+			updated(conf, "stage variable", name, "")
+		} else {
+			created(conf, "stage variable", name, "")
+		}
+		changes = append(changes, Change{Action: action, Type: "stage variable", Name: name})
+		patches = append(patches, variablePatch(ag.OpReplace, name, desired[name]))
+	}
+
+	for _, name := range sortedKeys(current) {
+		if _, wanted := desired[name]; wanted {
+			continue
+		}
+
+		deleted(conf, "stage variable", name)
+		changes = append(changes, Change{Action: "delete", Type: "stage variable", Name: name})
+		patches = append(patches, variablePatch(ag.OpRemove, name, ""))
+	}
+
+	if len(patches) == 0 {
+		return nil, nil
+	}
+	return changes, patchStage(patches, conf)
+}
+
+func checkVariableName(name string) error {
+	if reservedVariable(name) {
+		return &ValidationError{Err: fmt.Errorf("'%v' is set by launch and can't be changed", name)}
+	}
+	if !variableNamePattern.MatchString(name) {
+		return &ValidationError{Err: fmt.Errorf("'%v' must only contain lowercase letters, digits and underscores", name)}
+	}
+	return nil
+}
+
+func getStage(client *ag.APIGateway, conf *Config) (*ag.RestApi, *ag.Stage, error) {
+	api, err := getAPI(client, conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if api == nil {
+		return nil, nil, &NotFoundError{Err: fmt.Errorf("API '%v' doesn't exist, deploy it first", apiName(conf))}
+	}
+
+	stage, err := client.GetStage(&ag.GetStageInput{
+		RestApiId: api.Id,
+		StageName: aws.String(conf.Environment),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to find stage '%v': %w", conf.Environment, err)
+	}
+	return api, stage, nil
+}
+
+func patchStage(patches []*ag.PatchOperation, conf *Config) error {
+	client := ag.New(conf.Session)
+	api, _, err := getStage(client, conf)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.UpdateStage(&ag.UpdateStageInput{
+		RestApiId:       api.Id,
+		StageName:       aws.String(conf.Environment),
+		PatchOperations: patches,
+	})
+	return err
+}
+
+func variablePatch(op, name, value string) *ag.PatchOperation {
+	patch := &ag.PatchOperation{
+		Op:   aws.String(op),
+		Path: aws.String("/variables/" + name),
+	}
+	if op != ag.OpRemove {
+		patch.Value = aws.String(value)
+	}
+	return patch
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,35 @@
+package launch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckVariableName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"database_url", true},
+		{"api2", true},
+		{"DATABASE_URL", false},
+		{"databaseUrl", false},
+		{"database-url", false},
+		{"", false},
+		{"environment", false},
+		{"Environment", false},
+		{"staticbucket", false},
+	}
+
+	for _, tt := range tests {
+		err := checkVariableName(tt.name)
+		if (err == nil) != tt.valid {
+			t.Errorf("expected '%v' to be valid: %v, got %v", tt.name, tt.valid, err)
+		}
+
+		var validation *ValidationError
+		if err != nil && !errors.As(err, &validation) {
+			t.Errorf("expected a ValidationError for '%v', got %T", tt.name, err)
+		}
+	}
+}
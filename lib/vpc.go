@@ -0,0 +1,137 @@
+package launch
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// vpcSettings returns the VPC settings of the current environment.
+func vpcSettings(conf *Config) VPC {
+	if settings, defined := conf.VPC.Environments[conf.Environment]; defined {
+		return settings
+	}
+	return conf.VPC
+}
+
+// vpcConfigured tells whether the function is placed in a VPC in any environment.
+func vpcConfigured(conf *Config) bool {
+	if len(conf.VPC.Subnets) > 0 {
+		return true
+	}
+	for _, settings := range conf.VPC.Environments {
+		if len(settings.Subnets) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func vpcConfig(conf *Config) *lambda.VpcConfig {
+	settings := vpcSettings(conf)
+	return &lambda.VpcConfig{
+		SubnetIds:        aws.StringSlice(settings.Subnets),
+		SecurityGroupIds: aws.StringSlice(settings.SecurityGroups),
+	}
+}
+
+// vpcUpToDate compares the VPC settings of a function with those of the current environment.
+func vpcUpToDate(current *lambda.VpcConfigResponse, conf *Config) bool {
+	settings := vpcSettings(conf)
+	if current == nil {
+		return len(settings.Subnets) == 0
+	}
+	return sameSet(aws.StringValueSlice(current.SubnetIds), settings.Subnets) &&
+		sameSet(aws.StringValueSlice(current.SecurityGroupIds), settings.SecurityGroups)
+}
+
+// CheckVPC warns about subnets without a NAT route when the app needs to reach the
+// internet. Functions in a VPC never get public IPs, so an internet gateway isn't enough.
+func CheckVPC(conf *Config) error {
+	settings := vpcSettings(conf)
+	if !settings.Internet || len(settings.Subnets) == 0 {
+		return nil
+	}
+
+	client := ec2.New(conf.Session)
+
+	subnets, err := client.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		SubnetIds: aws.StringSlice(settings.Subnets),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to look up subnets: %w", err)
+	}
+
+	for _, subnet := range subnets.Subnets {
+		table, err := subnetRouteTable(client, subnet)
+		if err != nil {
+			return fmt.Errorf("unable to look up routes of subnet '%v': %w", *subnet.SubnetId, err)
+		}
+
+		if table == nil || !hasNATRoute(table) {
+			warn(conf, "subnet '%v' has no NAT route, the app won't be able to reach the internet", *subnet.SubnetId)
+		}
+	}
+
+	return nil
+}
+
+// subnetRouteTable returns the route table associated with a subnet, or the main table of
+// its VPC when it has none of its own.
+func subnetRouteTable(client *ec2.EC2, subnet *ec2.Subnet) (*ec2.RouteTable, error) {
+	filters := [][]*ec2.Filter{
+		{
+			{Name: aws.String("association.subnet-id"), Values: []*string{subnet.SubnetId}},
+		},
+		{
+			{Name: aws.String("vpc-id"), Values: []*string{subnet.VpcId}},
+			{Name: aws.String("association.main"), Values: aws.StringSlice([]string{"true"})},
+		},
+	}
+
+	for _, filter := range filters {
+		tables, err := client.DescribeRouteTables(&ec2.DescribeRouteTablesInput{Filters: filter})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tables.RouteTables) > 0 {
+			return tables.RouteTables[0], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func hasNATRoute(table *ec2.RouteTable) bool {
+	for _, route := range table.Routes {
+		if aws.StringValue(route.DestinationCidrBlock) != "0.0.0.0/0" {
+			continue
+		}
+		if route.NatGatewayId != nil || route.InstanceId != nil || route.TransitGatewayId != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package launch
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestVPCUpToDate(t *testing.T) {
+	conf := &Config{Environment: "dev", VPC: VPC{
+		Subnets:        []string{"subnet-a", "subnet-b"},
+		SecurityGroups: []string{"sg-a"},
+		Environments: map[string]VPC{
+			"prod": {Subnets: []string{"subnet-c"}, SecurityGroups: []string{"sg-c"}},
+		},
+	}}
+
+	tests := []struct {
+		name        string
+		environment string
+		current     *lambda.VpcConfigResponse
+		want        bool
+	}{
+		{"same subnets in another order", "dev", &lambda.VpcConfigResponse{
+			SubnetIds:        aws.StringSlice([]string{"subnet-b", "subnet-a"}),
+			SecurityGroupIds: aws.StringSlice([]string{"sg-a"}),
+		}, true},
+		{"missing subnet", "dev", &lambda.VpcConfigResponse{
+			SubnetIds:        aws.StringSlice([]string{"subnet-a"}),
+			SecurityGroupIds: aws.StringSlice([]string{"sg-a"}),
+		}, false},
+		{"not in a VPC", "dev", nil, false},
+		{"environment settings", "prod", &lambda.VpcConfigResponse{
+			SubnetIds:        aws.StringSlice([]string{"subnet-c"}),
+			SecurityGroupIds: aws.StringSlice([]string{"sg-c"}),
+		}, true},
+		{"environment without a VPC", "test", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf.Environment = tt.environment
+			if got := vpcUpToDate(tt.current, conf); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVPCConfigured(t *testing.T) {
+	tests := []struct {
+		vpc  VPC
+		want bool
+	}{
+		{VPC{}, false},
+		{VPC{Subnets: []string{"subnet-a"}}, true},
+		{VPC{Environments: map[string]VPC{"prod": {Subnets: []string{"subnet-a"}}}}, true},
+		{VPC{Environments: map[string]VPC{"prod": {Internet: true}}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := vpcConfigured(&Config{VPC: tt.vpc}); got != tt.want {
+			t.Errorf("expected %+v to be configured: %v, got %v", tt.vpc, tt.want, got)
+		}
+	}
+}
+
+func TestHasNATRoute(t *testing.T) {
+	tests := []struct {
+		name  string
+		route *ec2.Route
+		want  bool
+	}{
+		{"NAT gateway", &ec2.Route{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String("nat-1")}, true},
+		{"NAT instance", &ec2.Route{DestinationCidrBlock: aws.String("0.0.0.0/0"), InstanceId: aws.String("i-1")}, true},
+		{"internet gateway", &ec2.Route{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-1")}, false},
+		{"local route", &ec2.Route{DestinationCidrBlock: aws.String("10.0.0.0/16"), NatGatewayId: aws.String("nat-1")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &ec2.RouteTable{Routes: []*ec2.Route{tt.route}}
+			if got := hasNATRoute(table); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
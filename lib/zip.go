@@ -26,26 +26,41 @@ func WriteZipToFile(name string, conf *Config) error {
 }
 
 func ZipWorkingDir(conf *Config) (*bytes.Buffer, error) {
-	fmt.Println("Zipping files...")
+	if err := runHook("pre-package", conf.Hooks.PrePackage, hookEnv(nil, conf), conf); err != nil {
+		return nil, err
+	}
+
+	info(conf, "Zipping files...")
 	out := new(bytes.Buffer)
-	source := "."
+	source := sourceDir(conf)
+	excluded := excludedPaths(conf)
 
 	archive := zip.NewWriter(out)
 	defer archive.Close()
 
-	baseDir := filepath.Base(source)
-
 	filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		name, err := filepath.Rel(source, path)
+		if err != nil || name == "." {
+			return err
+		}
+
+		if excluded[name] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		header, err := zip.FileInfoHeader(info)
 		if err != nil {
 			return err
 		}
 
-		header.Name = filepath.Join(baseDir, strings.TrimPrefix(path, source))
+		header.Name = filepath.ToSlash(name)
 
 		if info.IsDir() {
 			header.Name += "/"
@@ -78,6 +93,49 @@ func ZipWorkingDir(conf *Config) (*bytes.Buffer, error) {
 	return out, nil
 }
 
+// excludedPaths returns the paths, relative to the source directory, that are packaged
+// separately and shouldn't be part of the function. Static files are served from S3.
+func excludedPaths(conf *Config) map[string]bool {
+	excluded := map[string]bool{}
+
+	var dirs []string
+	for _, route := range conf.Routes {
+		dirs = append(dirs, RouteConfig(route, conf).Source)
+	}
+	if staticEnabled(conf) {
+		dirs = append(dirs, conf.Static.Dir)
+	}
+
+	for _, dir := range dirs {
+		if rel, inSource := sourceRelative(dir, conf); inSource {
+			excluded[rel] = true
+		}
+	}
+
+	return excluded
+}
+
+// sourceRelative returns a path given relative to the working directory, relative to the
+// source directory instead. It reports false for paths outside the source directory.
+func sourceRelative(path string, conf *Config) (string, bool) {
+	source, err := filepath.Abs(sourceDir(conf))
+	if err != nil {
+		return "", false
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(source, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return rel, true
+}
+
 func appendShim(archive *zip.Writer, conf *Config) error {
 	shim, err := Shim(conf)
 	if err != nil {
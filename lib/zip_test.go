@@ -0,0 +1,53 @@
+package launch
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSourceRelative(t *testing.T) {
+	tests := []struct {
+		source, path string
+		want         string
+		inSource     bool
+	}{
+		{"", "api", "api", true},
+		{".", "./api/", "api", true},
+		{"app", "app/api", "api", true},
+		{"./app", "app/nested/api", filepath.Join("nested", "api"), true},
+		{"app", "app", "", false},
+		{"app", "api", "", false},
+		{"app", "app-api", "", false},
+		{"app/web", "app", "", false},
+	}
+
+	for _, tt := range tests {
+		rel, inSource := sourceRelative(tt.path, &Config{Source: tt.source})
+		if rel != tt.want || inSource != tt.inSource {
+			t.Errorf("sourceRelative(%q) in %q: got %q, %v, want %q, %v", tt.path, tt.source, rel, inSource, tt.want, tt.inSource)
+		}
+	}
+}
+
+func TestExcludedPaths(t *testing.T) {
+	conf := &Config{
+		Source: "app",
+		Routes: []Route{
+			{Name: "admin", Path: "/admin", Source: "app/admin"},
+			{Name: "reports", Path: "/reports"},
+			{Name: "billing", Path: "/billing", Source: "billing"},
+		},
+	}
+
+	want := map[string]bool{"admin": true}
+	if got := excludedPaths(conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected only route sources inside the app source to be excluded, got %v", got)
+	}
+
+	conf.Source = ""
+	want = map[string]bool{filepath.Join("app", "admin"): true, "reports": true, "billing": true}
+	if got := excludedPaths(conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected every route source to be excluded from the working directory, got %v, want %v", got, want)
+	}
+}